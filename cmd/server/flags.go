@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// migrateCommand is a one-off migration to run instead of starting the
+// server
+type migrateCommand struct {
+	direction string // "up" or "down", empty to start the server
+	steps     int    // how many migrations to roll back when going down
+}
+
+// parseFlags reads the command line, which is either empty, to migrate up
+// and start the server as usual, or one of:
+//
+//	-migrate up       apply any new migrations and exit
+//	-migrate down [N] roll back the last N migrations (default 1) and exit
+func parseFlags(args []string, output io.Writer) (migrateCommand, error) {
+	var cmd migrateCommand
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cmd.direction, "migrate", "", "run migrations `up` or `down [N]` and exit")
+
+	if err := fs.Parse(args); err != nil {
+		return cmd, err
+	}
+
+	switch cmd.direction {
+	case "":
+		if fs.NArg() != 0 {
+			return cmd, fmt.Errorf("unexpected arguments %q", fs.Args())
+		}
+	case "up":
+		if fs.NArg() != 0 {
+			return cmd, fmt.Errorf("-migrate up takes no arguments, got %q", fs.Args())
+		}
+	case "down":
+		cmd.steps = 1
+		if fs.NArg() > 1 {
+			return cmd, fmt.Errorf("-migrate down takes at most one argument, got %q", fs.Args())
+		}
+		if fs.NArg() == 1 {
+			steps, err := strconv.Atoi(fs.Arg(0))
+			if err != nil || steps < 1 {
+				return cmd, fmt.Errorf("-migrate down needs a positive number of steps, got %q", fs.Arg(0))
+			}
+			cmd.steps = steps
+		}
+	default:
+		return cmd, fmt.Errorf("-migrate must be up or down, got %q", cmd.direction)
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		args      []string
+		expected  migrateCommand
+		expectErr bool
+	}{
+		{args: []string{}, expected: migrateCommand{}},
+		{args: []string{"-migrate", "up"}, expected: migrateCommand{direction: "up"}},
+		{args: []string{"-migrate=up"}, expected: migrateCommand{direction: "up"}},
+		{args: []string{"-migrate", "down"}, expected: migrateCommand{direction: "down", steps: 1}},
+		{args: []string{"-migrate", "down", "3"}, expected: migrateCommand{direction: "down", steps: 3}},
+		{args: []string{"-migrate", "down", "0"}, expectErr: true},
+		{args: []string{"-migrate", "down", "two"}, expectErr: true},
+		{args: []string{"-migrate", "down", "1", "2"}, expectErr: true},
+		{args: []string{"-migrate", "up", "2"}, expectErr: true},
+		{args: []string{"-migrate", "sideways"}, expectErr: true},
+		{args: []string{"-migrate"}, expectErr: true},
+		{args: []string{"serve"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		cmd, err := parseFlags(tt.args, io.Discard)
+		if tt.expectErr {
+			assert.Error(t, err, tt.args)
+			continue
+		}
+
+		assert.NoError(t, err, tt.args)
+		assert.Equal(t, tt.expected, cmd, tt.args)
+	}
+}
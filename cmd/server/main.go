@@ -13,8 +13,10 @@ import (
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/purge"
 	"github.com/devict/job-board/pkg/server"
 	"github.com/devict/job-board/pkg/services"
+	"github.com/jmoiron/sqlx"
 
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -32,75 +34,172 @@ func main() {
 	log.Println("sucessful shutdown")
 }
 
+// emailCheckInterval is how often to look for subscribers due their weekly
+// digest and posters due an expiry reminder
+const emailCheckInterval = time.Hour
+
 func run() error {
+	migration, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to parseFlags: %w", err)
+	}
+
 	c, err := config.LoadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to LoadConfig: %w", err)
 	}
 
+	if migration.direction == "down" {
+		if err := data.MigrateDown(c, migration.steps); err != nil {
+			return fmt.Errorf("migrations failed: %w", err)
+		}
+		return nil
+	}
+
 	// migrate the db on startup
 	if err := data.Migrate(c); err != nil {
 		return fmt.Errorf("migrations failed: %w", err)
 	}
 
+	if migration.direction == "up" {
+		return nil
+	}
+
 	// get our database connection
 	db, err := sql.Open("postgres", c.DatabaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to sqlx.Open: %w", err)
 	}
 
+	var slackService *services.SlackService
+	if c.SlackHook != "" || c.SlackToken != "" {
+		slackService = &services.SlackService{Conf: c}
+	}
+
 	// TODO: what to do with the background job?
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	wg := sync.WaitGroup{}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		ticker := time.NewTicker(time.Hour)
-		defer ticker.Stop()
-
-		for {
+	sqlxDb := sqlx.NewDb(db, "postgres")
+	purgeDone := purge.StartPurgeLoop(ctx, &purge.Purger{
+		Interval:   c.PurgeInterval,
+		MaxBackoff: c.PurgeMaxBackoff,
+		Purge: func() error {
 			log.Println("removing old jobs")
-			_, err := db.Exec("DELETE FROM jobs WHERE published_at < NOW() - INTERVAL '30 DAYS'")
+			dbCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			defer cancel()
+
+			removed, err := data.DeleteExpiredJobs(dbCtx, sqlxDb)
 			if err != nil {
-				log.Println(fmt.Errorf("error clearing old jobs: %w", err))
+				return err
 			}
 
-			select {
-			case <-ctx.Done():
-				log.Println("shutting down old jobs background process")
-				return
-			case <-ticker.C:
-				continue
+			log.Printf("removed %d old jobs", len(removed))
+
+			// Only once they're gone, so a failed purge doesn't announce
+			// the same jobs again on the next try
+			if slackService != nil && c.NotifySlack && c.SlackExpiryNotices {
+				notifyExpiredJobs(removed, slackService)
 			}
-		}
-	}()
+
+			if c.DBSessions {
+				if _, err := data.DeleteExpiredSessions(dbCtx, sqlxDb); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+
+	notifications := server.NewNotificationQueue(100)
 
 	conf := &server.ServerConfig{
-		Config:       c,
-		DB:           db,
-		TemplatePath: "./templates",
+		Config:        c,
+		DB:            db,
+		TemplatePath:  "./templates",
+		AssetPath:     "./assets",
+		Notifications: notifications,
 	}
 
-	if c.Email.SMTPHost != "" {
+	if c.Email.SESEnabled {
+		ses, err := services.NewSESService(c.Email)
+		if err != nil {
+			return fmt.Errorf("failed to NewSESService: %w", err)
+		}
+		conf.EmailService = ses
+	} else if c.Email.SendGridAPIKey != "" {
+		conf.EmailService = &services.SendGridService{Conf: c.Email}
+	} else if c.Email.SMTPHost != "" {
 		conf.EmailService = &services.EmailService{Conf: c.Email}
+	} else if c.RequireConfirmation {
+		log.Println("email is not configured, so jobs will be published without confirmation")
+		c.RequireConfirmation = false
+	}
+
+	if c.RequireApproval && !c.AdminEnabled() {
+		log.Println("admin is not configured, so jobs will be published without approval")
+		c.RequireApproval = false
 	}
 
-	if c.SlackHook != "" {
-		conf.SlackService = &services.SlackService{Conf: c}
+	if slackService != nil {
+		conf.SlackService = slackService
+	}
+
+	if c.DiscordHook != "" {
+		conf.DiscordService = &services.DiscordService{Conf: c}
+	}
+
+	if c.MastodonURL != "" && c.MastodonToken != "" {
+		conf.MastodonService = &services.MastodonService{Conf: c}
+	}
+
+	if len(c.WebhookURLs) != 0 {
+		conf.WebhookService = &services.WebhookService{Conf: c}
 	}
 
 	if c.Twitter.APIKey != "" {
 		conf.TwitterService = &services.TwitterService{Conf: c}
 	}
 
+	if c.CaptchaSecret != "" {
+		conf.CaptchaService = &services.CaptchaService{Conf: c}
+	}
+
+	emailDone := make(chan struct{})
+	go func() {
+		defer close(emailDone)
+		if conf.EmailService == nil {
+			return
+		}
+
+		ticker := time.NewTicker(emailCheckInterval)
+		defer ticker.Stop()
+		for {
+			if err := server.SendDigests(ctx, sqlxDb, conf.EmailService, c); err != nil {
+				log.Println(fmt.Errorf("error sending digests: %w", err))
+			}
+
+			if c.ExpiryReminderLeadTime > 0 {
+				if err := server.SendExpiryReminders(ctx, sqlxDb, conf.EmailService, c); err != nil {
+					log.Println(fmt.Errorf("error sending expiry reminders: %w", err))
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
 	server, err := server.NewServer(conf)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %w", err)
 	}
 
+	wg := sync.WaitGroup{}
+
 	serverErrors := make(chan error, 1)
 	wg.Add(1)
 	go func() {
@@ -124,9 +223,32 @@ func run() error {
 		if err := server.Shutdown(context.Background()); err != nil {
 			return fmt.Errorf("failed to server.Shutdown: %w", err)
 		}
+
+		// handlers are done, so send anything they queued before exiting
+		log.Println("sending queued notifications")
+		notifications.Close()
 	}
 
 	wg.Wait()
+	<-purgeDone
+	<-emailDone
 
 	return nil
 }
+
+// notifyExpiredJobs follows up on the announcements of jobs that were just
+// purged
+func notifyExpiredJobs(jobs []data.Job, slackService services.ISlackService) {
+	for _, job := range jobs {
+		if !job.IsPublished() || job.DeletedAt.Valid {
+			// never announced, or already followed up on when it was
+			// deleted, so there's nothing to do
+			continue
+		}
+
+		if err := slackService.PostExpiredToSlack(job); err != nil {
+			log.Println(fmt.Errorf("failed to postExpiredToSlack: %w", err))
+			// continuing...
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSlack struct {
+	expired []data.Job
+}
+
+func (svc *mockSlack) PostToSlack(job data.Job) (string, error) {
+	return "", nil
+}
+
+func (svc *mockSlack) PostExpiredToSlack(job data.Job) error {
+	svc.expired = append(svc.expired, job)
+	return nil
+}
+
+func TestNotifyExpiredJobs(t *testing.T) {
+	published := data.Job{ID: "1", Confirmed: true, Status: data.StatusApproved}
+	unconfirmed := data.Job{ID: "2", Status: data.StatusApproved}
+	deleted := data.Job{ID: "3", Confirmed: true, Status: data.StatusApproved, DeletedAt: sql.NullTime{Time: time.Now(), Valid: true}}
+
+	slack := &mockSlack{}
+	notifyExpiredJobs([]data.Job{published, unconfirmed, deleted}, slack)
+
+	// Only jobs that were announced, and not already followed up on
+	if assert.Len(t, slack.expired, 1) {
+		assert.Equal(t, "1", slack.expired[0].ID)
+	}
+}
@@ -2,9 +2,15 @@ package config
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
@@ -15,14 +21,214 @@ type Config struct {
 	DatabaseURL string `envconfig:"DATABASE_URL" required:"true"`
 	Email       *EmailConfig
 	Twitter     *TwitterConfig
+	S3          *S3Config
 	SlackHook   string `envconfig:"SLACK_HOOK"`
+
+	// How long the edit, delete and confirmation links emailed to posters
+	// work for. Zero makes them last as long as the job.
+	EditLinkLifetime time.Duration `envconfig:"EDIT_LINK_LIFETIME" default:"720h"`
+
+	// APP_SECRET can be a comma separated list, so it can be rotated
+	// without breaking links that are already out there. AppSecret is the
+	// first, which everything is signed with, and the rest are still
+	// accepted for verification.
+	PreviousAppSecrets []string `ignored:"true"`
+
+	// Posting through the Slack Web API instead of the webhook lets us
+	// thread follow-ups under the original announcement.
+	SlackToken         string `envconfig:"SLACK_TOKEN"`
+	SlackChannel       string `envconfig:"SLACK_CHANNEL"`
+	SlackExpiryNotices bool   `envconfig:"SLACK_EXPIRY_NOTICES"`
+
+	// text/template formats for new job tweets and Slack posts, rendered
+	// with NotificationFields. The built-in messages are used when unset.
+	TweetTemplate string `envconfig:"TWEET_TEMPLATE"`
+	SlackTemplate string `envconfig:"SLACK_TEMPLATE"`
+
+	DiscordHook string `envconfig:"DISCORD_WEBHOOK"`
+
+	// Origins allowed to frame the /embed/jobs widget, e.g.
+	// "https://devict.org,https://*.devict.org". Any origin when empty.
+	EmbedOrigins []string `envconfig:"EMBED_ORIGINS"`
+
+	// New jobs are POSTed as JSON to each of these, signed with AppSecret
+	WebhookURLs []string `envconfig:"WEBHOOK_URLS"`
+
+	MastodonURL   string `envconfig:"MASTODON_URL"`
+	MastodonToken string `envconfig:"MASTODON_ACCESS_TOKEN"`
+
+	InboundEmailKey string `envconfig:"INBOUND_EMAIL_SIGNING_KEY"`
+
+	// Keys partners send as "Authorization: Bearer <key>" to post jobs
+	// through POST /api/jobs. The API is disabled when empty.
+	APIKeys []string `envconfig:"API_KEYS"`
+
+	// Origins whose pages can call the API from the browser, e.g.
+	// "https://devict.org", or "*" for any. None when empty.
+	APIOrigins []string `envconfig:"API_CORS_ORIGINS"`
+
+	// How long a request's database queries can take before they're
+	// cancelled. Zero disables.
+	DBTimeout time.Duration `envconfig:"DB_TIMEOUT" default:"5s"`
+
+	// How long the job list on the index is reused before it's loaded from
+	// the database again. Zero disables.
+	JobListCacheTTL time.Duration `envconfig:"JOB_LIST_CACHE_TTL" default:"30s"`
+
+	// Jobs whose description is at least this similar to an existing one
+	// are held for review instead of being published. Zero disables.
+	DuplicateSimilarityThreshold float64 `envconfig:"DUPLICATE_SIMILARITY_THRESHOLD" default:"0.9"`
+
+	// Resubmissions of a job (same organization, position, and email)
+	// within this window redirect to the existing posting. Zero disables.
+	DuplicateWindow time.Duration `envconfig:"DUPLICATE_WINDOW" default:"1h"`
+
+	// The most characters the position, organization, and description can
+	// have. Zero disables the limit.
+	MaxPositionLength     int `envconfig:"MAX_POSITION_LENGTH" default:"120"`
+	MaxOrganizationLength int `envconfig:"MAX_ORGANIZATION_LENGTH" default:"120"`
+	MaxDescriptionLength  int `envconfig:"MAX_DESCRIPTION_LENGTH" default:"10000"`
+
+	// A file listing email domains, one per line, that jobs can't be
+	// posted from, on top of the built-in list of disposable email
+	// providers. BlockedEmailDomains is loaded from it by LoadConfig.
+	BlockedEmailDomainsFile string   `envconfig:"BLOCKED_EMAIL_DOMAINS_FILE"`
+	BlockedEmailDomains     []string `ignored:"true"`
+
+	// Custom fields posters can fill in, shown on the job page in this
+	// order, e.g. "visa_sponsorship,security_clearance"
+	AllowedMetadataKeys []string `envconfig:"ALLOWED_METADATA_KEYS"`
+
+	// Collapse multiple postings from one organization into a single
+	// expandable entry on the index.
+	GroupByOrg bool `envconfig:"GROUP_BY_ORG"`
+
+	// When set, /healthz only reports detailed readiness information to
+	// requests that provide this token.
+	HealthCheckToken string `envconfig:"HEALTH_CHECK_TOKEN"`
+
+	// Directory uploaded logos are stored in, unless S3_BUCKET is set.
+	// Uploads are disabled if both are empty.
+	UploadDir string `envconfig:"UPLOAD_DIR" default:"uploads"`
+
+	// How long browsers can cache static assets for. Zero disables.
+	AssetMaxAge time.Duration `envconfig:"ASSET_MAX_AGE" default:"168h"`
+
+	// Gzip responses for clients that accept it
+	Gzip bool `envconfig:"GZIP" default:"true"`
+
+	// Notifications can be switched off without removing the service's
+	// credentials. NotifyEmail only covers the email sent when a job is
+	// posted, since confirmation links have to go out regardless.
+	NotifyEmail   bool `envconfig:"NOTIFY_EMAIL" default:"true"`
+	NotifySlack   bool `envconfig:"NOTIFY_SLACK" default:"true"`
+	NotifyTwitter bool `envconfig:"NOTIFY_TWITTER" default:"true"`
+
+	// The session cookie's name, which needs changing when another app on
+	// the same domain uses the default, and how long sessions last
+	SessionCookieName string        `envconfig:"SESSION_COOKIE_NAME" default:"mysession"`
+	SessionMaxAge     time.Duration `envconfig:"SESSION_MAX_AGE" default:"24h"`
+
+	// Sessions are kept in Redis instead of the cookie when set, e.g.
+	// "redis://:password@localhost:6379/0"
+	RedisURL string `envconfig:"REDIS_URL"`
+
+	// Sessions are kept in the database's http_sessions table instead of
+	// the cookie, taking precedence over RedisURL
+	DBSessions bool `envconfig:"DB_SESSIONS"`
+
+	// New jobs stay hidden until the poster follows the link emailed to them
+	RequireConfirmation bool `envconfig:"REQUIRE_CONFIRMATION" default:"true"`
+
+	// How many database-heavy page renders can run at once before new
+	// ones are turned away with a 503. Zero disables the limit.
+	MaxConcurrentHeavyRequests int `envconfig:"MAX_CONCURRENT_HEAVY_REQUESTS" default:"32"`
+
+	// CAPTCHA verification on the new job form is skipped unless the
+	// secret is set. The provider is either hcaptcha or recaptcha.
+	CaptchaProvider string `envconfig:"CAPTCHA_PROVIDER" default:"hcaptcha"`
+	CaptchaSiteKey  string `envconfig:"CAPTCHA_SITE_KEY"`
+	CaptchaSecret   string `envconfig:"CAPTCHA_SECRET"`
+
+	// How often expired jobs are purged, and the longest to wait between
+	// attempts while purging keeps failing
+	PurgeInterval   time.Duration `envconfig:"PURGE_INTERVAL" default:"1h"`
+	PurgeMaxBackoff time.Duration `envconfig:"PURGE_MAX_BACKOFF" default:"24h"`
+
+	// How many jobs a single IP can submit per minute. Zero disables.
+	SubmissionsPerMinute int `envconfig:"SUBMISSIONS_PER_MINUTE" default:"5"`
+	// Rather than a bare 429, send posters whose submission was valid back
+	// to the form, filled in, with a note saying how long to wait.
+	RateLimitGrace bool `envconfig:"RATE_LIMIT_GRACE"`
+
+	// A banner shown across the top of every page until it's dismissed,
+	// linking to AnnouncementURL when that's set
+	AnnouncementText string `envconfig:"ANNOUNCEMENT_TEXT"`
+	AnnouncementURL  string `envconfig:"ANNOUNCEMENT_URL"`
+
+	// The /admin pages are behind basic auth with these credentials, and
+	// disabled unless both are set or there are AdminAccounts
+	AdminUser     string `envconfig:"ADMIN_USER"`
+	AdminPassword string `envconfig:"ADMIN_PASSWORD"`
+
+	// Admin usernames mapped to bcrypt hashes of their passwords, as
+	// "alice:hash,bob:hash"
+	AdminAccounts map[string]string `envconfig:"ADMIN_ACCOUNTS"`
+
+	// New jobs stay hidden until they're approved from the admin page
+	RequireApproval bool `envconfig:"REQUIRE_APPROVAL"`
+
+	// How many previous versions of each job to keep for reverting edits
+	// from the admin page. Zero keeps none.
+	MaxJobRevisions int `envconfig:"MAX_JOB_REVISIONS" default:"10"`
+
+	// How long before a job expires to email its poster a reminder. Zero
+	// disables.
+	ExpiryReminderLeadTime time.Duration `envconfig:"EXPIRY_REMINDER_LEAD_TIME" default:"72h"`
+
+	// The IANA time zone dates are shown in, e.g. "America/Chicago".
+	// DisplayLocation is loaded from it by LoadConfig.
+	DisplayTimezone string         `envconfig:"DISPLAY_TIMEZONE" default:"UTC"`
+	DisplayLocation *time.Location `ignored:"true"`
+}
+
+// NotificationFields are what TWEET_TEMPLATE and SLACK_TEMPLATE can use
+type NotificationFields struct {
+	Position     string
+	Organization string
+	// Url is the job's page on the board, not the poster's application link
+	Url string
 }
 
+// EmailConfig's SMTP variables are only required when email goes through
+// SMTP, i.e. neither SendGrid nor SES is set up
 type EmailConfig struct {
-	SMTPHost     string `envconfig:"SMTP_HOST" required:"true"`
+	SMTPHost     string `envconfig:"SMTP_HOST"`
 	FromEmail    string `envconfig:"FROM_EMAIL" required:"true"`
-	SMTPUsername string `envconfig:"SMTP_USERNAME" required:"true"`
-	SMTPPassword string `envconfig:"SMTP_PASSWORD" required:"true"`
+	SMTPUsername string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
+
+	// Email is sent through the SendGrid API instead of SMTP when set
+	SendGridAPIKey string `envconfig:"SENDGRID_API_KEY"`
+
+	// Email is sent through Amazon SES instead of SMTP when enabled, with
+	// credentials from the usual AWS environment variables or instance
+	// role. SESRegion falls back to AWS_REGION.
+	SESEnabled bool   `envconfig:"SES_ENABLED"`
+	SESRegion  string `envconfig:"SES_REGION"`
+}
+
+// S3Config is where uploads are stored instead of UPLOAD_DIR when Bucket is
+// set, with credentials from the usual AWS environment variables or
+// instance role. Endpoint points at an S3-compatible server like MinIO
+// instead of AWS, and PublicURL is where objects are downloaded from when
+// that isn't the bucket itself, e.g. a CDN.
+type S3Config struct {
+	Bucket    string `envconfig:"S3_BUCKET"`
+	Region    string `envconfig:"S3_REGION"`
+	Endpoint  string `envconfig:"S3_ENDPOINT"`
+	Prefix    string `envconfig:"S3_PREFIX"`
+	PublicURL string `envconfig:"S3_PUBLIC_URL"`
 }
 
 type TwitterConfig struct {
@@ -34,10 +240,9 @@ type TwitterConfig struct {
 
 func LoadConfig() (*Config, error) {
 	var config Config
+	var errs configErrors
 
-	if err := envconfig.Process("", &config); err != nil {
-		return &config, err
-	}
+	errs = append(errs, processEach("", &config)...)
 
 	if !strings.Contains(config.DatabaseURL, "sslmode=disable") {
 		config.DatabaseURL = fmt.Sprintf("%s?sslmode=disable", config.DatabaseURL)
@@ -47,5 +252,187 @@ func LoadConfig() (*Config, error) {
 		config.Port = ":" + config.Port
 	}
 
+	env, err := normalizeEnv(config.Env)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	config.Env = env
+
+	if secrets := strings.Split(config.AppSecret, ","); len(secrets) > 1 {
+		config.AppSecret = strings.TrimSpace(secrets[0])
+		for _, secret := range secrets[1:] {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				config.PreviousAppSecrets = append(config.PreviousAppSecrets, secret)
+			}
+		}
+	}
+
+	if env == "release" && config.AppSecret != "" && len(config.AppSecret) < minAppSecretLength {
+		errs = append(errs, fmt.Errorf("APP_SECRET must be at least %d characters in release", minAppSecretLength))
+	}
+
+	if !config.Email.SESEnabled && config.Email.SendGridAPIKey == "" {
+		for _, key := range []string{"SMTP_HOST", "SMTP_USERNAME", "SMTP_PASSWORD"} {
+			if _, ok := os.LookupEnv(key); !ok {
+				errs = append(errs, fmt.Errorf("required key %s missing value", key))
+			}
+		}
+	}
+
+	// A purge loop that never waits would hammer the database
+	if config.PurgeInterval <= 0 {
+		errs = append(errs, fmt.Errorf("PURGE_INTERVAL must be positive, got %s", config.PurgeInterval))
+	}
+
+	if config.CaptchaProvider != "hcaptcha" && config.CaptchaProvider != "recaptcha" {
+		errs = append(errs, fmt.Errorf("invalid CAPTCHA_PROVIDER %q, must be hcaptcha or recaptcha", config.CaptchaProvider))
+	}
+
+	// Without a site key the form has no widget, so nobody could post
+	if config.CaptchaSecret != "" && config.CaptchaSiteKey == "" {
+		errs = append(errs, fmt.Errorf("CAPTCHA_SITE_KEY must be set along with CAPTCHA_SECRET"))
+	}
+
+	for user, hash := range config.AdminAccounts {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			errs = append(errs, fmt.Errorf("ADMIN_ACCOUNTS password for %q must be a bcrypt hash: %w", user, err))
+		}
+	}
+
+	for name, tmpl := range map[string]string{"TWEET_TEMPLATE": config.TweetTemplate, "SLACK_TEMPLATE": config.SlackTemplate} {
+		// Executing it too catches fields that don't exist, which only fail
+		// when the template is rendered
+		t, err := template.New(name).Parse(tmpl)
+		if err == nil {
+			err = t.Execute(io.Discard, NotificationFields{})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+		}
+	}
+
+	if config.BlockedEmailDomainsFile != "" {
+		domains, err := readDomainsFile(config.BlockedEmailDomainsFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid BLOCKED_EMAIL_DOMAINS_FILE: %w", err))
+		}
+		config.BlockedEmailDomains = domains
+	}
+
+	loc, err := time.LoadLocation(config.DisplayTimezone)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid DISPLAY_TIMEZONE %q: %w", config.DisplayTimezone, err))
+	}
+	config.DisplayLocation = loc
+
+	if len(errs) != 0 {
+		return &config, errs
+	}
+
 	return &config, nil
 }
+
+// AdminEnabled reports whether there's anyone who can sign in to /admin
+func (c *Config) AdminEnabled() bool {
+	return (c.AdminUser != "" && c.AdminPassword != "") || len(c.AdminAccounts) != 0
+}
+
+// AppSecrets is every secret signatures are accepted from, starting with
+// the one they're made with
+func (c *Config) AppSecrets() []string {
+	return append([]string{c.AppSecret}, c.PreviousAppSecrets...)
+}
+
+// minAppSecretLength is the shortest APP_SECRET accepted in release, since
+// it signs both sessions and edit links.
+const minAppSecretLength = 32
+
+// configErrors collects every problem with the config so they can all be
+// fixed at once, rather than one restart at a time.
+type configErrors []error
+
+func (errs configErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, "  - "+err.Error())
+	}
+
+	return fmt.Sprintf("%d config error(s):\n%s", len(errs), strings.Join(lines, "\n"))
+}
+
+// processEach runs envconfig over every field of spec on its own, since
+// envconfig.Process stops at the first missing or malformed variable.
+// Nested struct pointers are allocated and recursed into with the same
+// prefix envconfig would give them.
+func processEach(prefix string, spec interface{}) []error {
+	var errs []error
+
+	v := reflect.ValueOf(spec).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Tag.Get("ignored") == "true" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+			inner := reflect.New(field.Type.Elem())
+			errs = append(errs, processEach(strings.ToUpper(field.Name), inner.Interface())...)
+			v.Field(i).Set(inner)
+			continue
+		}
+
+		single := reflect.New(reflect.StructOf([]reflect.StructField{field}))
+		if err := envconfig.Process(prefix, single.Interface()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		v.Field(i).Set(single.Elem().Field(0))
+	}
+
+	return errs
+}
+
+// envAliases maps commonly used environment names onto the modes gin
+// understands (debug, release, test).
+var envAliases = map[string]string{
+	"debug":       "debug",
+	"dev":         "debug",
+	"development": "debug",
+	"local":       "debug",
+	"release":     "release",
+	"prod":        "release",
+	"production":  "release",
+	"test":        "test",
+	"testing":     "test",
+}
+
+func normalizeEnv(env string) (string, error) {
+	mode, ok := envAliases[strings.ToLower(strings.TrimSpace(env))]
+	if !ok {
+		return "", fmt.Errorf("invalid APP_ENV %q, must be one of debug, release, or test", env)
+	}
+
+	return mode, nil
+}
+
+// readDomainsFile reads a list of domains, one per line, skipping blank
+// lines and # comments
+func readDomainsFile(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			domains = append(domains, line)
+		}
+	}
+	return domains, nil
+}
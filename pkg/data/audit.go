@@ -0,0 +1,57 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ActorPoster is who's recorded for changes made through a job's signed
+// edit link, since posters don't have accounts
+const ActorPoster = "poster via token"
+
+// Actions recorded in the audit log
+const (
+	AuditUpdate    = "update"
+	AuditDelete    = "delete"
+	AuditRestore   = "restore"
+	AuditApprove   = "approve"
+	AuditReject    = "reject"
+	AuditFeature   = "feature"
+	AuditUnfeature = "unfeature"
+	AuditRevert    = "revert"
+)
+
+// AuditEntry is a record of someone changing a job. There's no foreign key
+// on the job, so entries outlive jobs that have been purged.
+type AuditEntry struct {
+	ID        string    `db:"id"`
+	JobID     string    `db:"job_id"`
+	Actor     string    `db:"actor"`
+	Action    string    `db:"action"`
+	CreatedAt time.Time `db:"created_at"`
+
+	// Position is empty once the job has been purged
+	Position string `db:"position"`
+}
+
+func RecordAudit(ctx context.Context, jobID, actor, action string, db *sqlx.DB) error {
+	_, err := db.ExecContext(
+		ctx,
+		"INSERT INTO audit_log (job_id, actor, action) VALUES ($1, $2, $3)",
+		jobID,
+		actor,
+		action,
+	)
+	return err
+}
+
+// GetAuditLog returns the most recent audit entries, newest first
+func GetAuditLog(ctx context.Context, limit int, db *sqlx.DB) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := db.SelectContext(ctx, &entries, `SELECT audit_log.*, COALESCE(jobs.position, '') AS position
+		FROM audit_log LEFT JOIN jobs ON jobs.id = audit_log.job_id
+		ORDER BY audit_log.created_at DESC, audit_log.id DESC LIMIT $1`, limit)
+	return entries, err
+}
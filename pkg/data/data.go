@@ -2,12 +2,18 @@ package data
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/mail"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/yuin/goldmark"
@@ -15,33 +21,105 @@ import (
 )
 
 type Job struct {
-	ID           string         `db:"id"`
-	Position     string         `db:"position"`
-	Organization string         `db:"organization"`
-	Url          sql.NullString `db:"url"`
-	Description  sql.NullString `db:"description"`
-	Email        string         `db:"email"`
-	PublishedAt  time.Time      `db:"published_at"`
+	ID           string         `db:"id" json:"id"`
+	Position     string         `db:"position" json:"position"`
+	Organization string         `db:"organization" json:"organization"`
+	Url          sql.NullString `db:"url" json:"url"`
+	Description  sql.NullString `db:"description" json:"description"`
+	Email        string         `db:"email" json:"-"`
+	PublishedAt  time.Time      `db:"published_at" json:"published_at"`
+	NeedsReview  bool           `db:"needs_review" json:"-"`
+	LogoUrl      sql.NullString `db:"logo_url" json:"logo_url"`
+	SlackTS      sql.NullString `db:"slack_ts" json:"-"`
+	Confirmed    bool           `db:"confirmed" json:"-"`
+	Metadata     Metadata       `db:"metadata" json:"metadata,omitempty"`
+	DeletedAt    sql.NullTime   `db:"deleted_at" json:"-"`
+	Status       string         `db:"status" json:"-"`
+	Featured     bool           `db:"featured" json:"featured"`
+	ApplyCount   int            `db:"apply_count" json:"-"`
+	ReminderSent bool           `db:"reminder_sent" json:"-"`
+	Slug         string         `db:"slug" json:"-"`
 }
 
+// Where a job is in moderation. Only approved jobs are shown publicly.
 const (
-	ErrNoPosition         = "Must provide a Position"
-	ErrNoOrganization     = "Must provide a Organization"
-	ErrNoEmail            = "Must provide an Email Address"
-	ErrInvalidUrl         = "Must provide a valid Url"
-	ErrInvalidEmail       = "Must provide a valid Email"
-	ErrNoUrlOrDescription = "Must provide either a Url or a Description"
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// ValidTransition reports whether a job can be moved from one moderation
+// status to another. Pending jobs can be approved or rejected, and staff
+// can change their minds afterwards, but nothing goes back to pending.
+func ValidTransition(from, to string) bool {
+	switch to {
+	case StatusApproved:
+		return from == StatusPending || from == StatusRejected
+	case StatusRejected:
+		return from == StatusPending || from == StatusApproved
+	default:
+		return false
+	}
+}
+
+// MarshalJSON flattens the nullable columns into plain strings, omitted
+// when null. The poster's email and internal state are never included.
+func (job Job) MarshalJSON() ([]byte, error) {
+	type alias Job
+	return json.Marshal(struct {
+		alias
+		Url         string `json:"url,omitempty"`
+		Description string `json:"description,omitempty"`
+		LogoUrl     string `json:"logo_url,omitempty"`
+	}{
+		alias:       alias(job),
+		Url:         job.Url.String,
+		Description: job.Description.String,
+		LogoUrl:     job.LogoUrl.String,
+	})
+}
+
+// Validation errors are message keys, translated by the handler for the
+// visitor's language with the i18n package
+const (
+	ErrNoPosition          = "error.no_position"
+	ErrNoOrganization      = "error.no_organization"
+	ErrNoEmail             = "error.no_email"
+	ErrInvalidUrl          = "error.invalid_url"
+	ErrInvalidEmail        = "error.invalid_email"
+	ErrNoUrlOrDescription  = "error.no_url_or_description"
+	ErrInvalidLogo         = "error.invalid_logo"
+	ErrLogoTooLarge        = "error.logo_too_large"
+	ErrCaptchaFailed       = "error.captcha_failed"
+	ErrInvalidMetadata     = "error.invalid_metadata"
+	ErrPositionTooLong     = "error.position_too_long"
+	ErrOrganizationTooLong = "error.organization_too_long"
+	ErrUrlTooLong          = "error.url_too_long"
+	ErrDescriptionTooLong  = "error.description_too_long"
+	ErrEmailTooLong        = "error.email_too_long"
+	ErrMetadataTooLong     = "error.metadata_too_long"
+	ErrDisposableEmail     = "error.disposable_email"
+	ErrPositionMarkup      = "error.position_markup"
+	ErrOrganizationMarkup  = "error.organization_markup"
 )
 
 func (job *Job) Update(newParams NewJob) {
 	job.Position = newParams.Position
 	job.Organization = newParams.Organization
+	job.Slug = Slugify(job.Position, job.Organization)
 
 	job.Url.String = newParams.Url
 	job.Url.Valid = newParams.Url != ""
 
 	job.Description.String = newParams.Description
 	job.Description.Valid = newParams.Description != ""
+
+	job.Metadata = newParams.Metadata
+
+	// The logo is only replaced when a new one was uploaded
+	if newParams.LogoUrl != "" {
+		job.LogoUrl = sql.NullString{String: newParams.LogoUrl, Valid: true}
+	}
 }
 
 func (job *Job) RenderDescription() (string, error) {
@@ -49,6 +127,17 @@ func (job *Job) RenderDescription() (string, error) {
 		return "", nil
 	}
 
+	html, err := RenderMarkdown(job.Description.String)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert job descroption to markdown (job id: %s): %w", job.ID, err)
+	}
+
+	return html, nil
+}
+
+// RenderMarkdown converts a description to HTML. Raw HTML and unsafe link
+// protocols are left out, so the result is safe to show as is.
+func RenderMarkdown(source string) (string, error) {
 	markdown := goldmark.New(
 		goldmark.WithExtensions(
 			extension.NewLinkify(
@@ -61,24 +150,316 @@ func (job *Job) RenderDescription() (string, error) {
 	)
 
 	var b bytes.Buffer
-	if err := markdown.Convert([]byte(job.Description.String), &b); err != nil {
-		return "", fmt.Errorf("failed to convert job descroption to markdown (job id: %s): %w", job.ID, err)
+	if err := markdown.Convert([]byte(source), &b); err != nil {
+		return "", err
 	}
 
 	return b.String(), nil
 }
 
-func (job *Job) Save(db *sqlx.DB) (sql.Result, error) {
-	return db.Exec(
-		"UPDATE jobs SET position = $1, organization = $2, url = $3, description = $4 WHERE id = $5",
-		job.Position, job.Organization, job.Url, job.Description, job.ID,
+const updateJobQuery = "UPDATE jobs SET position = $1, organization = $2, url = $3, description = $4, metadata = $5, logo_url = $6, slug = $7 WHERE id = $8"
+
+// Save updates the job, first keeping its current version as a revision.
+// Only the latest maxRevisions are kept, and none are when it's zero.
+func (job *Job) Save(ctx context.Context, db *sqlx.DB, maxRevisions int) (sql.Result, error) {
+	args := []interface{}{job.Position, job.Organization, job.Url, job.Description, job.Metadata, job.LogoUrl, job.Slug, job.ID}
+	if maxRevisions <= 0 {
+		return db.ExecContext(ctx, updateJobQuery, args...)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Does nothing once committed
+	defer tx.Rollback()
+
+	if err := saveRevision(ctx, tx, job.ID, maxRevisions); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, updateJobQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update job: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return result, nil
+}
+
+func ConfirmJob(ctx context.Context, id string, db *sqlx.DB) error {
+	_, err := db.ExecContext(ctx, "UPDATE jobs SET confirmed = true WHERE id = $1", id)
+	return err
+}
+
+// SetJobStatus moves a job from one moderation status to another, failing
+// if the transition isn't valid or the job isn't in the from status
+func SetJobStatus(ctx context.Context, id string, from, to string, db *sqlx.DB) error {
+	if !ValidTransition(from, to) {
+		return fmt.Errorf("can't move job from %s to %s", from, to)
+	}
+
+	result, err := db.ExecContext(ctx, "UPDATE jobs SET status = $1 WHERE id = $2 AND status = $3", to, id, from)
+	if err != nil {
+		return fmt.Errorf("failed to set job status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("expected to update 1 job, updated %d", rowsAffected)
+	}
+
+	return nil
+}
+
+// ReviewJob approves or rejects a job that was held for review, whatever
+// its status, and clears the hold
+func ReviewJob(ctx context.Context, id string, status string, db *sqlx.DB) error {
+	if status != StatusApproved && status != StatusRejected {
+		return fmt.Errorf("can't review job as %s", status)
+	}
+
+	result, err := db.ExecContext(ctx, "UPDATE jobs SET status = $1, needs_review = FALSE WHERE id = $2 AND needs_review", status, id)
+	if err != nil {
+		return fmt.Errorf("failed to review job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("expected to update 1 job, updated %d", rowsAffected)
+	}
+
+	return nil
+}
+
+// ToggleJobFeatured features a job above the rest of the listing, or stops
+// featuring it if it already was, returning whether it's now featured
+func ToggleJobFeatured(ctx context.Context, id string, db *sqlx.DB) (bool, error) {
+	var featured bool
+	err := db.GetContext(ctx, &featured, "UPDATE jobs SET featured = NOT featured WHERE id = $1 RETURNING featured", id)
+	return featured, err
+}
+
+// RecordApply counts a click on the job's apply link, returning the url to
+// send them on to. It's empty for jobs without a url or that aren't
+// published, which aren't counted.
+func RecordApply(ctx context.Context, id string, db *sqlx.DB) (string, error) {
+	var url string
+	err := db.GetContext(
+		ctx,
+		&url,
+		`UPDATE jobs SET apply_count = apply_count + 1
+		WHERE id = $1 AND url IS NOT NULL
+		AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL
+		RETURNING url`,
+		id,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return url, nil
+}
+
+// GetPendingJobs returns the jobs waiting on moderation, including ones
+// held for review, oldest first
+func GetPendingJobs(ctx context.Context, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	err := db.SelectContext(ctx, &jobs, "SELECT * FROM jobs WHERE (status = 'pending' OR needs_review) AND deleted_at IS NULL ORDER BY published_at")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return jobs, err
+	}
+
+	return jobs, nil
+}
+
+func SetJobSlackTS(ctx context.Context, id string, ts string, db *sqlx.DB) error {
+	_, err := db.ExecContext(ctx, "UPDATE jobs SET slack_ts = $1 WHERE id = $2", ts, id)
+	return err
+}
+
+// DeleteJob hides a job everywhere on the board. The row is kept so the
+// job can be restored until it expires and is purged.
+func DeleteJob(ctx context.Context, id string, db *sqlx.DB) error {
+	result, err := db.ExecContext(ctx, "UPDATE jobs SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobNotFound
+	} else if rowsAffected != 1 {
+		return fmt.Errorf("expected to delete 1 job with id %q, deleted %d", id, rowsAffected)
+	}
+
+	return nil
+}
+
+// RestoreJob undoes DeleteJob
+func RestoreJob(ctx context.Context, id string, db *sqlx.DB) error {
+	result, err := db.ExecContext(ctx, "UPDATE jobs SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return fmt.Errorf("failed to restore job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("expected to restore 1 job, restored %d", rowsAffected)
+	}
+
+	return nil
+}
+
+// GetDeletedJobs returns the jobs that have been deleted but not yet
+// purged, most recently deleted first
+func GetDeletedJobs(ctx context.Context, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	err := db.SelectContext(ctx, &jobs, "SELECT * FROM jobs WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return jobs, err
+	}
+
+	return jobs, nil
+}
+
+func GetAllJobs(ctx context.Context, db *sqlx.DB) ([]Job, error) {
+	return GetJobsSorted(ctx, SortNewest, db)
+}
+
+// Orders the job listing can be sorted in. Featured jobs always come first.
+const (
+	SortNewest       = "newest"
+	SortOldest       = "oldest"
+	SortOrganization = "organization"
+)
+
+var sortClauses = map[string]string{
+	SortNewest:       "featured DESC, published_at DESC",
+	SortOldest:       "featured DESC, published_at ASC",
+	SortOrganization: "featured DESC, lower(organization), published_at DESC",
+}
+
+// ValidSort returns sort if it's one of the orders above, otherwise
+// SortNewest
+func ValidSort(sort string) string {
+	if _, ok := sortClauses[sort]; ok {
+		return sort
+	}
+	return SortNewest
+}
+
+// GetJobsSorted returns the published jobs in the given order, or newest
+// first if it isn't one of the orders above
+func GetJobsSorted(ctx context.Context, sort string, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	query := fmt.Sprintf(
+		"SELECT * FROM jobs WHERE confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL ORDER BY %s",
+		sortClauses[ValidSort(sort)],
+	)
+	err := db.SelectContext(ctx, &jobs, query)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return jobs, err
+	}
+
+	return jobs, nil
+}
+
+// DeleteExpiredJobs removes jobs old enough to be purged, deleted or not,
+// returning the jobs that were removed so they can be followed up on.
+func DeleteExpiredJobs(ctx context.Context, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	err := db.SelectContext(ctx, &jobs, "DELETE FROM jobs WHERE published_at < NOW() - INTERVAL '30 DAYS' RETURNING *")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// DeleteExpiredSessions removes sessions from http_sessions that have
+// expired, since the session store only ignores them
+func DeleteExpiredSessions(ctx context.Context, db *sqlx.DB) (int64, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM http_sessions WHERE expires_on < NOW()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// JobLifetime is how long a job stays up before it's purged
+const JobLifetime = 30 * 24 * time.Hour
+
+// ExpiresAt is when the job will be purged
+func (job Job) ExpiresAt() time.Time {
+	return job.PublishedAt.Add(JobLifetime)
+}
+
+// GetJobsDueReminder returns the published jobs that expire within leadTime
+// of now and whose posters haven't been reminded yet
+func GetJobsDueReminder(ctx context.Context, now time.Time, leadTime time.Duration, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	err := db.SelectContext(
+		ctx,
+		&jobs,
+		`SELECT * FROM jobs WHERE published_at < $1 AND NOT reminder_sent
+			AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL
+			ORDER BY published_at`,
+		now.Add(leadTime).Add(-JobLifetime),
 	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return jobs, err
+	}
+
+	return jobs, nil
+}
+
+// MarkReminderSent records that a job's poster was reminded it's expiring,
+// so they're only emailed once
+func MarkReminderSent(ctx context.Context, id string, db *sqlx.DB) error {
+	_, err := db.ExecContext(ctx, "UPDATE jobs SET reminder_sent = true WHERE id = $1", id)
+	return err
 }
 
-func GetAllJobs(db *sqlx.DB) ([]Job, error) {
+// GetJobsByOrganization returns an organization's published jobs, newest
+// first
+func GetJobsByOrganization(ctx context.Context, organization string, db *sqlx.DB) ([]Job, error) {
 	var jobs []Job
 
-	err := db.Select(&jobs, "SELECT * FROM jobs ORDER BY published_at DESC")
+	err := db.SelectContext(
+		ctx,
+		&jobs,
+		fmt.Sprintf(
+			"SELECT * FROM jobs WHERE organization = $1 AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL ORDER BY %s",
+			sortClauses[SortNewest],
+		),
+		organization,
+	)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return jobs, err
 	}
@@ -86,10 +467,63 @@ func GetAllJobs(db *sqlx.DB) ([]Job, error) {
 	return jobs, nil
 }
 
-func GetJob(id string, db *sqlx.DB) (Job, error) {
+// ErrJobNotFound is returned when there's no job with the given id, or it's
+// been deleted
+var ErrJobNotFound = errors.New("job not found")
+
+func GetJob(ctx context.Context, id string, db *sqlx.DB) (Job, error) {
+	var job Job
+
+	err := db.GetContext(ctx, &job, "SELECT * FROM jobs WHERE id = $1 AND deleted_at IS NULL", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrJobNotFound
+	}
+
+	return job, err
+}
+
+// GetPublishedJob is GetJob for public pages, which mustn't show jobs that
+// are unconfirmed, held for review, or not approved
+func GetPublishedJob(ctx context.Context, id string, db *sqlx.DB) (Job, error) {
+	var job Job
+
+	err := db.GetContext(
+		ctx,
+		&job,
+		"SELECT * FROM jobs WHERE id = $1 AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL",
+		id,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrJobNotFound
+	}
+
+	return job, err
+}
+
+// IsPublished reports whether the job is listed publicly, the in-memory
+// version of GetPublishedJob's filter
+func (job Job) IsPublished() bool {
+	return job.Confirmed && !job.NeedsReview && job.Status == StatusApproved
+}
+
+// FindRecentDuplicate returns a job from the same organization and email
+// for the same position posted within window, or an empty Job if there
+// isn't one.
+func FindRecentDuplicate(ctx context.Context, newJob NewJob, window time.Duration, db *sqlx.DB) (Job, error) {
 	var job Job
 
-	err := db.Get(&job, "SELECT * FROM jobs WHERE id = $1", id)
+	err := db.GetContext(
+		ctx,
+		&job,
+		`SELECT * FROM jobs
+		WHERE lower(organization) = lower($1) AND lower(position) = lower($2) AND lower(email) = lower($3)
+		AND published_at > $4 AND deleted_at IS NULL
+		ORDER BY published_at DESC LIMIT 1`,
+		newJob.Organization,
+		newJob.Position,
+		newJob.Email,
+		time.Now().Add(-window),
+	)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return job, err
 	}
@@ -103,43 +537,172 @@ type NewJob struct {
 	Url          string `form:"url"`
 	Description  string `form:"description"`
 	Email        string `form:"email"`
+
+	// LogoUrl is set from an uploaded file rather than bound from the form
+	LogoUrl string `form:"-"`
+	// Confirmed is false when the poster still has to confirm by email
+	Confirmed bool `form:"-"`
+	// Metadata is bound from the metadata[key] form map
+	Metadata Metadata `form:"-"`
+	// Status is StatusPending when staff have to approve the job before
+	// it's published, and defaults to StatusApproved
+	Status string `form:"-"`
+}
+
+// Limits caps how many characters each job field can have. Zero means no
+// limit.
+type Limits struct {
+	Position     int
+	Organization int
+	Url          int
+	Description  int
+	Email        int
+	// Metadata applies to each custom field's value
+	Metadata int
+}
+
+// Lengths beyond which urls and email addresses can't be valid anyway, and
+// a custom field value stops being a short answer
+const (
+	MaxUrlLength      = 2048
+	MaxEmailLength    = 254
+	MaxMetadataLength = 200
+)
+
+// tooLong reports whether s has more than max characters. Line breaks are
+// counted once, like a browser's maxlength does, even though forms submit
+// them as \r\n.
+func tooLong(s string, max int) bool {
+	return max > 0 && utf8.RuneCountInString(strings.ReplaceAll(s, "\r\n", "\n")) > max
+}
+
+// Normalize trims the whitespace posters tend to paste in around values,
+// so a field of only whitespace counts as empty, and drops control
+// characters. Validate normalizes the job before checking it.
+func (newJob *NewJob) Normalize() {
+	newJob.Position = strings.TrimSpace(singleLine(newJob.Position))
+	newJob.Organization = strings.TrimSpace(singleLine(newJob.Organization))
+	newJob.Url = strings.TrimSpace(singleLine(newJob.Url))
+	newJob.Description = strings.TrimSpace(stripControl(newJob.Description))
+	newJob.Email = strings.TrimSpace(singleLine(newJob.Email))
+
+	for key, value := range newJob.Metadata {
+		if value = strings.TrimSpace(singleLine(value)); value == "" {
+			delete(newJob.Metadata, key)
+		} else {
+			newJob.Metadata[key] = value
+		}
+	}
 }
 
-func (newJob *NewJob) Validate(update bool) map[string]string {
+// stripControl drops control characters other than line breaks and tabs
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// singleLine drops control characters, turning line breaks and tabs into
+// spaces, for fields that are shown on one line
+func singleLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		} else if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// markupPattern matches anything that looks like an html tag or comment
+var markupPattern = regexp.MustCompile(`<[/!?]?[a-zA-Z]|<!--`)
+
+func (newJob *NewJob) Validate(update bool, allowedMetadataKeys []string, limits Limits, blocked BlockedDomains) map[string]string {
+	newJob.Normalize()
+
 	errs := make(map[string]string)
 
 	if newJob.Position == "" {
 		errs["position"] = ErrNoPosition
+	} else if tooLong(newJob.Position, limits.Position) {
+		errs["position"] = ErrPositionTooLong
+	} else if markupPattern.MatchString(newJob.Position) {
+		errs["position"] = ErrPositionMarkup
 	}
 
 	if newJob.Organization == "" {
 		errs["organization"] = ErrNoOrganization
+	} else if tooLong(newJob.Organization, limits.Organization) {
+		errs["organization"] = ErrOrganizationTooLong
+	} else if markupPattern.MatchString(newJob.Organization) {
+		errs["organization"] = ErrOrganizationMarkup
 	}
 
+	newJob.Url = normalizeURL(newJob.Url)
 	if newJob.Url == "" && newJob.Description == "" {
 		errs["url"] = ErrNoUrlOrDescription
-	} else if newJob.Description == "" {
-		if _, err := url.ParseRequestURI(newJob.Url); err != nil {
-			errs["url"] = ErrInvalidUrl
-		}
+	} else if tooLong(newJob.Url, limits.Url) {
+		errs["url"] = ErrUrlTooLong
+	} else if newJob.Url != "" && !validURL(newJob.Url) {
+		errs["url"] = ErrInvalidUrl
+	}
+
+	if tooLong(newJob.Description, limits.Description) {
+		errs["description"] = ErrDescriptionTooLong
 	}
 
 	if !update {
 		if newJob.Email == "" {
 			errs["email"] = ErrNoEmail
-		} else if _, err := mail.ParseAddress(newJob.Email); err != nil {
-			// TODO: Maybe do more than just validate email format?
+		} else if tooLong(newJob.Email, limits.Email) {
+			errs["email"] = ErrEmailTooLong
+		} else if addr, err := mail.ParseAddress(newJob.Email); err != nil {
 			errs["email"] = ErrInvalidEmail
+		} else if blocked.Blocks(addr.Address) {
+			errs["email"] = ErrDisposableEmail
+		}
+	}
+
+	for key, value := range newJob.Metadata {
+		if !contains(allowedMetadataKeys, key) {
+			errs["metadata"] = ErrInvalidMetadata
+			break
+		}
+		if tooLong(value, limits.Metadata) {
+			errs["metadata"] = ErrMetadataTooLong
 		}
 	}
 
 	return errs
 }
 
-func (newJob *NewJob) SaveToDB(db *sqlx.DB) (Job, error) {
+// SaveToDB inserts the job, flagging it for review when its description is
+// at least similarityThreshold similar to an existing job's. A threshold of
+// zero disables the check.
+func (newJob *NewJob) SaveToDB(ctx context.Context, db *sqlx.DB, similarityThreshold float64) (Job, error) {
+	var job Job
+
+	needsReview := false
+	if similarityThreshold > 0 && newJob.Description != "" {
+		similar, err := hasSimilarDescription(ctx, db, newJob.Description, similarityThreshold)
+		if err != nil {
+			return job, fmt.Errorf("failed to check for similar descriptions: %w", err)
+		}
+		needsReview = similar
+	}
+
+	status := newJob.Status
+	if status == "" {
+		status = StatusApproved
+	}
+
 	query := `INSERT INTO jobs
-    (position, organization, url, description, email)
-    VALUES ($1, $2, $3, $4, $5)
+    (position, organization, url, description, email, needs_review, logo_url, confirmed, metadata, status, slug)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
     RETURNING *`
 
 	params := []interface{}{
@@ -154,11 +717,81 @@ func (newJob *NewJob) SaveToDB(db *sqlx.DB) (Job, error) {
 			Valid:  newJob.Description != "",
 		},
 		newJob.Email,
+		needsReview,
+		sql.NullString{
+			String: newJob.LogoUrl,
+			Valid:  newJob.LogoUrl != "",
+		},
+		newJob.Confirmed,
+		newJob.Metadata,
+		status,
+		Slugify(newJob.Position, newJob.Organization),
 	}
 
-	var job Job
-	if err := db.QueryRowx(query, params...).StructScan(&job); err != nil {
+	if err := db.QueryRowxContext(ctx, query, params...).StructScan(&job); err != nil {
 		return job, err
 	}
 	return job, nil
 }
+
+// hasSimilarDescription compares description against the jobs that are
+// still up, rather than every job ever posted
+func hasSimilarDescription(ctx context.Context, db *sqlx.DB, description string, threshold float64) (bool, error) {
+	var descriptions []string
+
+	err := db.SelectContext(
+		ctx,
+		&descriptions,
+		"SELECT description FROM jobs WHERE description IS NOT NULL AND deleted_at IS NULL AND published_at > $1",
+		time.Now().Add(-JobLifetime),
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	for _, existing := range descriptions {
+		if DescriptionSimilarity(description, existing) >= threshold {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validURL reports whether raw is an absolute http(s) url, ruling out
+// javascript: links and relative paths
+func validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// normalizeURL adds https:// to urls given without a scheme, like
+// "company.com/careers" or "company.com:8080/careers". url.Parse can't tell
+// the latter from a scheme, so it's looked for as "://" instead. Anything
+// that still doesn't look like it has a domain, or that has a user like
+// "mailto:jobs@company.com" would, is left as it was for validURL to reject.
+func normalizeURL(raw string) string {
+	if raw == "" || strings.Contains(raw, "://") {
+		return raw
+	}
+
+	withScheme := "https://" + raw
+	if u, err := url.Parse(withScheme); err != nil || u.User != nil || !strings.Contains(u.Hostname(), ".") {
+		return raw
+	}
+
+	return withScheme
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,19 @@
 package data
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
 )
 
 func TestValidate(t *testing.T) {
@@ -13,28 +25,770 @@ func TestValidate(t *testing.T) {
 	}
 
 	// test valid url format
-	result := testJob.Validate(false)
-	if result["url"] == "Must provide a valid Url" {
+	result := testJob.Validate(false, nil, Limits{}, nil)
+	if result["url"] == ErrInvalidUrl {
 		t.Error("valid url, should have no error - result was=", result["url"])
 	}
 
 	// test valid email format
-	result = testJob.Validate(false)
-	if result["email"] == "Must provide a valid Email" {
+	result = testJob.Validate(false, nil, Limits{}, nil)
+	if result["email"] == ErrInvalidEmail {
 		t.Error("valid email, should have no error - result was=", result["email"])
 	}
 
 	// test bad url format
 	testJob.Url = "https//test.com/"
-	result = testJob.Validate(false)
-	if result["url"] != "Must provide a valid Url" {
+	result = testJob.Validate(false, nil, Limits{}, nil)
+	if result["url"] != ErrInvalidUrl {
 		t.Error("bad url, should show an error - result was=", result["url"])
 	}
 
 	// test bad email format
 	testJob.Email = "testtest.com"
-	result = testJob.Validate(false)
-	if result["email"] != "Must provide a valid Email" {
+	result = testJob.Validate(false, nil, Limits{}, nil)
+	if result["email"] != ErrInvalidEmail {
 		t.Error("bad email, should show an error - result was=", result["email"])
 	}
 }
+
+func TestValidateURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://test.com/jobs":  true,
+		"http://test.com":        true,
+		"javascript:alert(1)":    false,
+		"//test.com/jobs":        false,
+		"/jobs/1":                false,
+		"https://":               false,
+		"ftp://test.com/job.txt": false,
+	}
+
+	for raw, valid := range tests {
+		testJob := &NewJob{
+			Position:     "test position",
+			Organization: "test org",
+			Url:          raw,
+			Description:  "a description doesn't excuse a bad url",
+			Email:        "test@test.com",
+		}
+
+		result := testJob.Validate(false, nil, Limits{}, nil)
+		if valid && result["url"] != "" {
+			t.Errorf("%q should be valid, got %q", raw, result["url"])
+		} else if !valid && result["url"] != ErrInvalidUrl {
+			t.Errorf("%q should be invalid, got %q", raw, result["url"])
+		}
+	}
+}
+
+func TestValidateAddsScheme(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+		valid    bool
+	}{
+		{"example.com", "https://example.com", true},
+		{"company.com/careers?id=1", "https://company.com/careers?id=1", true},
+		{"company.com:8080/careers", "https://company.com:8080/careers", true},
+		{"mailto:jobs@company.com", "mailto:jobs@company.com", false},
+		{"javascript:alert(1)", "javascript:alert(1)", false},
+		{"http://example.com", "http://example.com", true},
+		{"not a url", "not a url", false},
+		{"https//example.com/broken", "https//example.com/broken", false},
+	}
+
+	for _, test := range tests {
+		testJob := &NewJob{
+			Position:     "test position",
+			Organization: "test org",
+			Url:          test.url,
+			Email:        "test@test.com",
+		}
+
+		result := testJob.Validate(false, nil, Limits{}, nil)
+		if testJob.Url != test.expected {
+			t.Errorf("expected %q to be stored as %q, got %q", test.url, test.expected, testJob.Url)
+		}
+
+		if test.valid && result["url"] != "" {
+			t.Errorf("%q should be valid, got %q", test.url, result["url"])
+		} else if !test.valid && result["url"] != ErrInvalidUrl {
+			t.Errorf("%q should be invalid, got %q", test.url, result["url"])
+		}
+	}
+}
+
+func TestValidateTrimsWhitespace(t *testing.T) {
+	newJob := NewJob{
+		Position:     "  Developer\n",
+		Organization: "  Acme  ",
+		Url:          " devict.org ",
+		Description:  "\n\nCool job\r\n",
+		Email:        "test@example.com ",
+		Metadata:     Metadata{"visa": " Yes ", "remote": "   "},
+	}
+
+	if errs := newJob.Validate(false, []string{"visa", "remote"}, Limits{}, nil); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	expected := NewJob{
+		Position:     "Developer",
+		Organization: "Acme",
+		Url:          "https://devict.org",
+		Description:  "Cool job",
+		Email:        "test@example.com",
+		Metadata:     Metadata{"visa": "Yes"},
+	}
+	if !reflect.DeepEqual(newJob, expected) {
+		t.Errorf("expected %+v, got %+v", expected, newJob)
+	}
+
+	// Whitespace alone doesn't count as filling in a field
+	blank := NewJob{Position: " \t\n", Organization: "  ", Description: "\n", Email: " "}
+	errs := blank.Validate(false, nil, Limits{}, nil)
+	if errs["position"] != ErrNoPosition {
+		t.Errorf("expected %q for position, got %q", ErrNoPosition, errs["position"])
+	}
+	if errs["organization"] != ErrNoOrganization {
+		t.Errorf("expected %q for organization, got %q", ErrNoOrganization, errs["organization"])
+	}
+	if errs["url"] != ErrNoUrlOrDescription {
+		t.Errorf("expected %q for url, got %q", ErrNoUrlOrDescription, errs["url"])
+	}
+	if errs["email"] != ErrNoEmail {
+		t.Errorf("expected %q for email, got %q", ErrNoEmail, errs["email"])
+	}
+}
+
+func TestValidateMarkup(t *testing.T) {
+	tests := []struct {
+		position string
+		err      string
+	}{
+		{"Developer <3", ""},
+		{"C++ Developer (Remote) -> Hybrid", ""},
+		{"<b>Developer</b>", ErrPositionMarkup},
+		{"Developer <script>alert(1)</script>", ErrPositionMarkup},
+		{"Developer <!-- hi -->", ErrPositionMarkup},
+		// Control characters are dropped, and line breaks become spaces
+		{"Senior\nDeveloper\x00\x1b", ""},
+	}
+
+	for _, tt := range tests {
+		newJob := NewJob{Position: tt.position, Organization: "Org", Url: "https://devict.org", Email: "test@example.com"}
+		errs := newJob.Validate(false, nil, Limits{}, nil)
+		if errs["position"] != tt.err {
+			t.Errorf("%q: expected %q, got %q", tt.position, tt.err, errs["position"])
+		}
+	}
+
+	newJob := NewJob{Position: "Senior\nDeveloper\x00\x1b", Description: "Line one\n\tLine\x07 two"}
+	newJob.Normalize()
+	if newJob.Position != "Senior Developer" {
+		t.Errorf("expected control characters to be stripped from the position, got %q", newJob.Position)
+	}
+	if newJob.Description != "Line one\n\tLine two" {
+		t.Errorf("expected line breaks and tabs to be kept in the description, got %q", newJob.Description)
+	}
+
+	newJob = NewJob{Organization: "<i>Org</i>"}
+	if errs := newJob.Validate(true, nil, Limits{}, nil); errs["organization"] != ErrOrganizationMarkup {
+		t.Errorf("expected %q for organization, got %q", ErrOrganizationMarkup, errs["organization"])
+	}
+}
+
+func TestValidateLength(t *testing.T) {
+	limits := Limits{Position: 10, Organization: 10, Url: 30, Description: 20, Email: 20, Metadata: 5}
+	valid := NewJob{
+		Position:     "Pos",
+		Organization: "Org",
+		Url:          "https://devict.org",
+		Description:  "Line one\r\nLine two",
+		Email:        "test@example.com",
+		Metadata:     Metadata{"visa": "Yes"},
+	}
+	allowed := []string{"visa"}
+
+	if errs := valid.Validate(false, allowed, limits, nil); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	// Multibyte characters count once, as do submitted line breaks
+	exact := valid
+	exact.Position = strings.Repeat("é", 10)
+	exact.Description = strings.Repeat("a\r\n", 10)
+	if errs := exact.Validate(false, allowed, limits, nil); len(errs) != 0 {
+		t.Errorf("expected fields right at the limit to be valid, got %v", errs)
+	}
+
+	tests := []struct {
+		field string
+		edit  func(*NewJob)
+		err   string
+	}{
+		{"position", func(j *NewJob) { j.Position = strings.Repeat("a", 11) }, ErrPositionTooLong},
+		{"organization", func(j *NewJob) { j.Organization = strings.Repeat("a", 11) }, ErrOrganizationTooLong},
+		{"url", func(j *NewJob) { j.Url = "https://devict.org/" + strings.Repeat("a", 20) }, ErrUrlTooLong},
+		{"description", func(j *NewJob) { j.Description = strings.Repeat("a", 21) }, ErrDescriptionTooLong},
+		{"email", func(j *NewJob) { j.Email = strings.Repeat("a", 10) + "@example.com" }, ErrEmailTooLong},
+		{"metadata", func(j *NewJob) { j.Metadata = Metadata{"visa": "Sometimes"} }, ErrMetadataTooLong},
+	}
+
+	for _, tt := range tests {
+		job := valid
+		tt.edit(&job)
+
+		errs := job.Validate(false, allowed, limits, nil)
+		if errs[tt.field] != tt.err {
+			t.Errorf("%s: expected %q, got %v", tt.field, tt.err, errs)
+		}
+
+		// No limits, no errors
+		if errs := job.Validate(false, allowed, Limits{}, nil); len(errs) != 0 {
+			t.Errorf("%s: expected no errors without limits, got %v", tt.field, errs)
+		}
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	allowed := []string{"visa_sponsorship", "security_clearance"}
+	testJob := &NewJob{
+		Position:     "test position",
+		Organization: "test org",
+		Url:          "https://test.com/",
+		Email:        "test@test.com",
+		Metadata:     Metadata{"visa_sponsorship": "yes"},
+	}
+
+	// test allowed key
+	result := testJob.Validate(false, allowed, Limits{}, nil)
+	if _, ok := result["metadata"]; ok {
+		t.Error("allowed key, should have no error - result was=", result["metadata"])
+	}
+
+	// test disallowed key
+	testJob.Metadata["favorite_color"] = "blue"
+	result = testJob.Validate(false, allowed, Limits{}, nil)
+	if result["metadata"] != ErrInvalidMetadata {
+		t.Error("disallowed key, should show an error - result was=", result["metadata"])
+	}
+
+	// test no keys allowed
+	testJob.Metadata = Metadata{"visa_sponsorship": "yes"}
+	result = testJob.Validate(false, nil, Limits{}, nil)
+	if result["metadata"] != ErrInvalidMetadata {
+		t.Error("no keys allowed, should show an error - result was=", result["metadata"])
+	}
+}
+
+func TestMetadataFields(t *testing.T) {
+	var metadata Metadata
+	if err := metadata.Scan([]byte(`{"visa_sponsorship":"yes","remote":"","favorite_color":"blue"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := metadata.Fields([]string{"remote", "visa_sponsorship", "security_clearance"})
+	if len(fields) != 1 || fields[0] != (MetadataField{Key: "visa_sponsorship", Value: "yes"}) {
+		t.Error("expected only the allowed, non-empty field - result was=", fields)
+	}
+}
+
+func TestDescriptionSimilarity(t *testing.T) {
+	original := "We are hiring a senior Go developer to build our job board platform. " +
+		"You will work closely with the community team, write tests, review code, " +
+		"and help run our Postgres database in production."
+
+	// near duplicate, one word swapped
+	nearDuplicate := "We are hiring a senior Go developer to build our job board platform. " +
+		"You will work closely with the community team, write tests, review code, " +
+		"and help run our MySQL database in production."
+	if result := DescriptionSimilarity(original, nearDuplicate); result < 0.9 {
+		t.Error("near duplicate, should be at least 0.9 similar - result was=", result)
+	}
+
+	// identical, ignoring case and punctuation
+	if result := DescriptionSimilarity(original, strings.ToUpper(original)+"!!"); result != 1 {
+		t.Error("identical text, should be 1 - result was=", result)
+	}
+
+	// distinct
+	distinct := "Local bakery seeking a part-time web designer to refresh our menu site " +
+		"and set up online ordering before the holidays."
+	if result := DescriptionSimilarity(original, distinct); result > 0.1 {
+		t.Error("distinct text, should be dissimilar - result was=", result)
+	}
+
+	// empty
+	if result := DescriptionSimilarity("", original); result != 0 {
+		t.Error("empty text, should be 0 - result was=", result)
+	}
+}
+
+func TestFindRecentDuplicate(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	newJob := NewJob{Position: "Pos", Organization: "Org", Email: "test@example.com"}
+	columns := []string{"id", "position", "organization", "email"}
+
+	// match
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE lower\(organization\)`).
+		WithArgs("Org", "Pos", "test@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "Pos", "Org", "test@example.com"))
+
+	job, err := FindRecentDuplicate(context.Background(), newJob, time.Hour, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "1" {
+		t.Errorf("expected to find job 1, got %q", job.ID)
+	}
+
+	// no match
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE lower\(organization\)`).
+		WithArgs("Org", "Pos", "test@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	job, err = FindRecentDuplicate(context.Background(), newJob, time.Hour, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "" {
+		t.Errorf("expected no duplicate, got %q", job.ID)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow("1", "Pos"))
+
+	job, err := GetJob(ctx, "1", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "1" || job.Position != "Pos" {
+		t.Errorf("expected job 1, got %+v", job)
+	}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}))
+
+	if _, err := GetJob(ctx, "2", db); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("3").
+		WillReturnError(sql.ErrConnDone)
+
+	if _, err := GetJob(ctx, "3", db); !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("expected the query error, got %v", err)
+	}
+
+	// No jobs at all isn't an error
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE confirmed`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}))
+
+	jobs, err := GetAllJobs(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("expected no jobs, got %v", jobs)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetPublishedJob(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL`).
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow("1", "Pos"))
+
+	job, err := GetPublishedJob(ctx, "1", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "1" {
+		t.Errorf("expected job 1, got %+v", job)
+	}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND confirmed`).
+		WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}))
+
+	if _, err := GetPublishedJob(ctx, "2", db); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		job  Job
+		want bool
+	}{
+		{Job{Confirmed: true, Status: StatusApproved}, true},
+		{Job{Confirmed: false, Status: StatusApproved}, false},
+		{Job{Confirmed: true, Status: StatusPending}, false},
+		{Job{Confirmed: true, Status: StatusRejected}, false},
+		{Job{Confirmed: true, NeedsReview: true, Status: StatusApproved}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.job.IsPublished(); got != tt.want {
+			t.Errorf("IsPublished() = %v for %+v", got, tt.job)
+		}
+	}
+}
+
+func TestDeleteJob(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := DeleteJob(ctx, "1", db); err != nil {
+		t.Error(err)
+	}
+
+	// Already deleted, or never existed
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := DeleteJob(ctx, "2", db); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs("3").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err = DeleteJob(ctx, "3", db)
+	if err == nil || errors.Is(err, ErrJobNotFound) || strings.Contains(err.Error(), "%!") {
+		t.Errorf("expected an error about deleting 2 jobs, got %v", err)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteExpiredJobs(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	dbmock.ExpectQuery(`DELETE FROM jobs WHERE published_at < NOW\(\) - INTERVAL '30 DAYS' RETURNING \*`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow("1", "Pos 1").AddRow("2", "Pos 2"))
+
+	removed, err := DeleteExpiredJobs(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0].ID != "1" || removed[1].ID != "2" {
+		t.Errorf("expected jobs 1 and 2 to be returned, got %+v", removed)
+	}
+
+	// Nothing comes back when the delete fails
+	dbmock.ExpectQuery(`DELETE FROM jobs`).WillReturnError(fmt.Errorf("timeout"))
+
+	removed, err = DeleteExpiredJobs(context.Background(), db)
+	if err == nil || len(removed) != 0 {
+		t.Errorf("expected an error and no jobs, got %v and %+v", err, removed)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{StatusPending, StatusApproved, true},
+		{StatusPending, StatusRejected, true},
+		{StatusRejected, StatusApproved, true},
+		{StatusApproved, StatusRejected, true},
+		{StatusApproved, StatusApproved, false},
+		{StatusRejected, StatusRejected, false},
+		{StatusApproved, StatusPending, false},
+		{StatusRejected, StatusPending, false},
+		{StatusPending, "deleted", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidTransition(tt.from, tt.to); got != tt.expected {
+			t.Errorf("ValidTransition(%q, %q) = %t, expected %t", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
+
+func TestJobJSONFeatured(t *testing.T) {
+	b, err := json.Marshal(Job{ID: "1", Featured: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"featured":true`) {
+		t.Errorf("expected featured in %s", b)
+	}
+}
+
+func TestGetJobsSorted(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	tests := []struct {
+		sort          string
+		expectedOrder string
+	}{
+		{SortNewest, `ORDER BY featured DESC, published_at DESC$`},
+		{SortOldest, `ORDER BY featured DESC, published_at ASC$`},
+		{SortOrganization, `ORDER BY featured DESC, lower\(organization\), published_at DESC$`},
+		{"", `ORDER BY featured DESC, published_at DESC$`},
+		{"published_at; DROP TABLE jobs", `ORDER BY featured DESC, published_at DESC$`},
+	}
+
+	for _, tt := range tests {
+		dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE .+ ` + tt.expectedOrder).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if _, err := GetJobsSorted(context.Background(), tt.sort, db); err != nil {
+			t.Errorf("sort %q: %s", tt.sort, err)
+		}
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetJobsByOrganization(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE organization = \$1 AND confirmed .+ ORDER BY featured DESC, published_at DESC`).
+		WithArgs("Acme/Widgets").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "organization"}).AddRow("1", "Acme/Widgets"))
+
+	jobs, err := GetJobsByOrganization(context.Background(), "Acme/Widgets", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "1" {
+		t.Errorf("expected job 1, got %v", jobs)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetJobsDueReminder(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	// Three days' notice means reminding anything published more than 27
+	// days ago
+	now := time.Now()
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at < \$1 AND NOT reminder_sent\s+AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL`).
+		WithArgs(now.Add(-27 * 24 * time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow("1", "test@example.com"))
+
+	jobs, err := GetJobsDueReminder(ctx, now, 72*time.Hour, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Email != "test@example.com" {
+		t.Errorf("expected job 1, got %v", jobs)
+	}
+
+	dbmock.ExpectExec(`UPDATE jobs SET reminder_sent = true WHERE id = \$1`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := MarkReminderSent(ctx, "1", db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordAudit(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectExec(`INSERT INTO audit_log \(job_id, actor, action\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs("1", ActorPoster, AuditUpdate).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := RecordAudit(ctx, "1", ActorPoster, AuditUpdate, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Purged jobs have no position to join on
+	dbmock.ExpectQuery(`SELECT audit_log\.\*, COALESCE\(jobs\.position, ''\) AS position\s+FROM audit_log LEFT JOIN jobs`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "actor", "action", "created_at", "position"}).
+			AddRow("2", "1", "alice", AuditDelete, time.Now(), "Pos").
+			AddRow("1", "9", ActorPoster, AuditUpdate, time.Now(), ""))
+
+	entries, err := GetAuditLog(ctx, 10, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Actor != "alice" || entries[1].Position != "" {
+		t.Errorf("unexpected audit log %+v", entries)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaveJobRevisions(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	job := Job{ID: "1", Position: "New Pos", Organization: "Org"}
+	updateArgs := []driver.Value{"New Pos", "Org", sql.NullString{}, sql.NullString{}, "{}", sql.NullString{}, "", "1"}
+
+	// The old version is kept before updating, and only the latest 3 after
+	dbmock.ExpectBegin()
+	dbmock.ExpectExec(`INSERT INTO job_revisions \(job_id, position, organization, url, description, metadata, logo_url\)\s+SELECT id, position, organization, url, description, metadata, logo_url FROM jobs WHERE id = \$1`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbmock.ExpectExec(`DELETE FROM job_revisions WHERE job_id = \$1 AND id NOT IN`).
+		WithArgs("1", 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbmock.ExpectExec(`UPDATE jobs SET position = \$1`).
+		WithArgs(updateArgs...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.ExpectCommit()
+
+	if _, err := job.Save(ctx, db, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing's kept when revisions are turned off
+	dbmock.ExpectExec(`UPDATE jobs SET position = \$1`).
+		WithArgs(updateArgs...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := job.Save(ctx, db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed update doesn't leave a revision behind
+	dbmock.ExpectBegin()
+	dbmock.ExpectExec(`INSERT INTO job_revisions`).WillReturnResult(sqlmock.NewResult(1, 1))
+	dbmock.ExpectExec(`DELETE FROM job_revisions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	dbmock.ExpectExec(`UPDATE jobs SET position = \$1`).WillReturnError(fmt.Errorf("connection reset"))
+	dbmock.ExpectRollback()
+
+	if _, err := job.Save(ctx, db, 3); err == nil {
+		t.Error("expected an error when the update fails")
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJobRevisionApply(t *testing.T) {
+	job := Job{
+		ID:           "1",
+		Position:     "New Pos",
+		Organization: "New Org",
+		Email:        "test@example.com",
+		Metadata:     Metadata{"visa_sponsorship": "no"},
+	}
+	rev := JobRevision{
+		JobID:        "1",
+		Position:     "Old Pos",
+		Organization: "Old Org",
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Metadata:     Metadata{"visa_sponsorship": "yes"},
+	}
+
+	rev.Apply(&job)
+
+	expected := Job{
+		ID:           "1",
+		Position:     "Old Pos",
+		Organization: "Old Org",
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Email:        "test@example.com",
+		Metadata:     Metadata{"visa_sponsorship": "yes"},
+		Slug:         "old-pos-old-org",
+	}
+	if !reflect.DeepEqual(job, expected) {
+		t.Errorf("expected %+v, got %+v", expected, job)
+	}
+}
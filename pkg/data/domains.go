@@ -0,0 +1,60 @@
+package data
+
+import "strings"
+
+// DisposableEmailDomains are throwaway email providers that jobs can't be
+// posted from, on top of any configured ones
+var DisposableEmailDomains = []string{
+	"10minutemail.com",
+	"dispostable.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"guerrillamail.net",
+	"guerrillamail.org",
+	"mailinator.com",
+	"maildrop.cc",
+	"sharklasers.com",
+	"temp-mail.org",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// BlockedDomains is a set of email domains, lowercased
+type BlockedDomains map[string]bool
+
+// NewBlockedDomains builds a set from each of the lists of domains
+func NewBlockedDomains(lists ...[]string) BlockedDomains {
+	blocked := BlockedDomains{}
+	for _, list := range lists {
+		for _, domain := range list {
+			if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+				blocked[domain] = true
+			}
+		}
+	}
+	return blocked
+}
+
+// Blocks reports whether email is at one of the domains, or a subdomain
+// of one
+func (blocked BlockedDomains) Blocks(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := strings.Trim(strings.ToLower(email[at+1:]), ".>")
+	for domain != "" {
+		if blocked[domain] {
+			return true
+		}
+
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package data
+
+import "testing"
+
+func TestBlockedDomains(t *testing.T) {
+	blocked := NewBlockedDomains(DisposableEmailDomains, []string{" Spam.Example ", "", "."})
+
+	tests := []struct {
+		email   string
+		blocked bool
+	}{
+		{"someone@mailinator.com", true},
+		{"someone@MAILINATOR.COM", true},
+		{"someone@eu.mailinator.com", true},
+		{"someone@spam.example", true},
+		{"someone@devict.org", false},
+		{"someone@notmailinator.com", false},
+		{"someone@mailinator.com.devict.org", false},
+		{"not an email", false},
+	}
+
+	for _, tt := range tests {
+		if got := blocked.Blocks(tt.email); got != tt.blocked {
+			t.Errorf("%q: expected blocked to be %v, got %v", tt.email, tt.blocked, got)
+		}
+	}
+
+	newJob := NewJob{Position: "Pos", Organization: "Org", Url: "https://devict.org", Email: "Someone <someone@mailinator.com>"}
+	if errs := newJob.Validate(false, nil, Limits{}, blocked); errs["email"] != ErrDisposableEmail {
+		t.Errorf("expected %q, got %q", ErrDisposableEmail, errs["email"])
+	}
+
+	newJob.Email = "someone@devict.org"
+	if errs := newJob.Validate(false, nil, Limits{}, blocked); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
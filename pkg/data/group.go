@@ -0,0 +1,25 @@
+package data
+
+type JobGroup struct {
+	Organization string
+	Jobs         []Job
+}
+
+// GroupByOrganization groups jobs by organization, keeping the groups in the
+// order each organization first appears in jobs.
+func GroupByOrganization(jobs []Job) []JobGroup {
+	groups := []JobGroup{}
+	index := make(map[string]int)
+
+	for _, job := range jobs {
+		i, ok := index[job.Organization]
+		if !ok {
+			i = len(groups)
+			index[job.Organization] = i
+			groups = append(groups, JobGroup{Organization: job.Organization})
+		}
+		groups[i].Jobs = append(groups[i].Jobs, job)
+	}
+
+	return groups
+}
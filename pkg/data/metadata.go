@@ -0,0 +1,60 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Metadata holds a job's custom fields (visa sponsorship, security
+// clearance, etc), stored as a jsonb object. Which keys are accepted and
+// shown is up to the AllowedMetadataKeys config.
+type Metadata map[string]string
+
+type MetadataField struct {
+	Key   string
+	Value string
+}
+
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (m *Metadata) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", src)
+	}
+
+	return json.Unmarshal(b, m)
+}
+
+// Fields returns the non-empty values for keys, in the order given, so
+// the allowlist controls both what is shown and in what order.
+func (m Metadata) Fields(keys []string) []MetadataField {
+	var fields []MetadataField
+	for _, key := range keys {
+		if v := m[key]; v != "" {
+			fields = append(fields, MetadataField{Key: key, Value: v})
+		}
+	}
+
+	return fields
+}
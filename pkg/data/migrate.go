@@ -26,3 +26,26 @@ func Migrate(c *config.Config) error {
 	}
 	return nil
 }
+
+// MigrateDown rolls back the last steps migrations
+func MigrateDown(c *config.Config, steps int) error {
+	m, err := migrate.New("file://sql", c.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to migrate.New: %w", err)
+	}
+
+	if err := m.Steps(-steps); err != nil {
+		return fmt.Errorf("failed to migrate down %d: %w", steps, err)
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		log.Println("rolled back every migration")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	log.Printf("rolled back %d migration(s), now at version %d (dirty: %t)", steps, version, dirty)
+	return nil
+}
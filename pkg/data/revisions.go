@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// JobRevision is a job's editable fields as they were before an update
+type JobRevision struct {
+	ID           string         `db:"id"`
+	JobID        string         `db:"job_id"`
+	Position     string         `db:"position"`
+	Organization string         `db:"organization"`
+	Url          sql.NullString `db:"url"`
+	Description  sql.NullString `db:"description"`
+	Metadata     Metadata       `db:"metadata"`
+	LogoUrl      sql.NullString `db:"logo_url"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// Apply sets the job's editable fields back to the revision's
+func (rev JobRevision) Apply(job *Job) {
+	job.Position = rev.Position
+	job.Organization = rev.Organization
+	job.Slug = Slugify(rev.Position, rev.Organization)
+	job.Url = rev.Url
+	job.Description = rev.Description
+	job.Metadata = rev.Metadata
+	job.LogoUrl = rev.LogoUrl
+}
+
+// saveRevision copies the job's current version into job_revisions, then
+// drops all but its latest maxRevisions
+func saveRevision(ctx context.Context, tx *sqlx.Tx, jobID string, maxRevisions int) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO job_revisions (job_id, position, organization, url, description, metadata, logo_url)
+		SELECT id, position, organization, url, description, metadata, logo_url FROM jobs WHERE id = $1`,
+		jobID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM job_revisions WHERE job_id = $1 AND id NOT IN
+		(SELECT id FROM job_revisions WHERE job_id = $1 ORDER BY id DESC LIMIT $2)`,
+		jobID,
+		maxRevisions,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to prune revisions: %w", err)
+	}
+
+	return nil
+}
+
+// GetJobRevisions returns a job's revisions, newest first
+func GetJobRevisions(ctx context.Context, jobID string, db *sqlx.DB) ([]JobRevision, error) {
+	var revisions []JobRevision
+
+	err := db.SelectContext(ctx, &revisions, "SELECT * FROM job_revisions WHERE job_id = $1 ORDER BY id DESC", jobID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return revisions, err
+	}
+
+	return revisions, nil
+}
+
+// GetJobRevision returns one of a job's revisions, which is empty if the
+// revision doesn't exist or belongs to another job
+func GetJobRevision(ctx context.Context, jobID, id string, db *sqlx.DB) (JobRevision, error) {
+	var revision JobRevision
+
+	err := db.GetContext(ctx, &revision, "SELECT * FROM job_revisions WHERE id = $1 AND job_id = $2", id, jobID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return revision, err
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"strings"
+	"unicode"
+)
+
+const shingleSize = 2
+
+// DescriptionSimilarity returns the Dice coefficient (0 to 1) of the word
+// shingles in a and b, so descriptions that differ by a word or two still
+// score close to 1.
+func DescriptionSimilarity(a, b string) float64 {
+	sa, sb := shingles(a), shingles(b)
+	if len(sa) == 0 || len(sb) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for s := range sa {
+		if _, ok := sb[s]; ok {
+			intersection++
+		}
+	}
+
+	return 2 * float64(intersection) / float64(len(sa)+len(sb))
+}
+
+func shingles(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	set := make(map[string]struct{})
+	if len(words) < shingleSize {
+		if len(words) > 0 {
+			set[strings.Join(words, " ")] = struct{}{}
+		}
+		return set
+	}
+
+	for i := 0; i+shingleSize <= len(words); i++ {
+		set[strings.Join(words[i:i+shingleSize], " ")] = struct{}{}
+	}
+
+	return set
+}
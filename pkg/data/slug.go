@@ -0,0 +1,55 @@
+package data
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// maxSlugLength keeps the slugs of long positions to a readable length
+const maxSlugLength = 80
+
+// reservedSlugs are the routes under /jobs/:id/, which a job's page can't
+// use as its slug or it would be shadowed by them
+var reservedSlugs = map[string]bool{
+	"apply":   true,
+	"edit":    true,
+	"delete":  true,
+	"confirm": true,
+}
+
+// Slugify joins the words in parts with hyphens for use in a url, e.g.
+// "senior-go-engineer-acme". Letters are lowercased but otherwise kept as
+// they are, so positions in other scripts still get a readable slug.
+// Slugs that would be one of reservedSlugs get "-job" added.
+func Slugify(parts ...string) string {
+	var words []string
+	var length int
+	for _, word := range strings.FieldsFunc(strings.ToLower(strings.Join(parts, " ")), notSlugRune) {
+		length += len([]rune(word)) + 1
+		if length > maxSlugLength+1 && len(words) != 0 {
+			break
+		}
+		words = append(words, word)
+	}
+
+	slug := strings.Join(words, "-")
+	if reservedSlugs[slug] {
+		slug += "-job"
+	}
+	return slug
+}
+
+func notSlugRune(r rune) bool {
+	// Mn keeps combining accents with the letters they belong to
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+}
+
+// Path is the job's page on the board, including its slug once it has one
+func (job Job) Path() string {
+	if job.Slug == "" {
+		return "/jobs/" + job.ID
+	}
+
+	return "/jobs/" + job.ID + "/" + url.PathEscape(job.Slug)
+}
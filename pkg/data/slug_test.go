@@ -0,0 +1,54 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		parts    []string
+		expected string
+	}{
+		{[]string{"Senior Go Engineer", "Acme"}, "senior-go-engineer-acme"},
+		{[]string{"C++ / C# Developer (Remote!)", "O'Reilly & Sons, Inc."}, "c-c-developer-remote-o-reilly-sons-inc"},
+		{[]string{"  --Lead--  ", "..."}, "lead"},
+		{[]string{"Développeur Full-Stack", "Café Über"}, "développeur-full-stack-café-über"},
+		// a decomposed é keeps its accent rather than splitting the word
+		{[]string{"Cafe\u0301 Bar"}, "cafe\u0301-bar"},
+		{[]string{"ソフトウェアエンジニア", "株式会社"}, "ソフトウェアエンジニア-株式会社"},
+		{[]string{"!!!"}, ""},
+		// slugs can't shadow the routes under /jobs/:id/
+		{[]string{"Apply", "!!!"}, "apply-job"},
+		{[]string{"Edit"}, "edit-job"},
+		{[]string{"Delete", ""}, "delete-job"},
+		{[]string{"CONFIRM"}, "confirm-job"},
+		{[]string{"Apply", "Acme"}, "apply-acme"},
+	}
+
+	for _, test := range tests {
+		if slug := Slugify(test.parts...); slug != test.expected {
+			t.Errorf("expected %v to be %q, got %q", test.parts, test.expected, slug)
+		}
+	}
+}
+
+func TestSlugifyLength(t *testing.T) {
+	slug := Slugify(strings.Repeat("engineer ", 20), "Acme")
+	if len([]rune(slug)) > maxSlugLength {
+		t.Errorf("expected at most %d runes, got %q", maxSlugLength, slug)
+	}
+	if strings.HasSuffix(slug, "-") || !strings.HasSuffix(slug, "engineer") {
+		t.Errorf("expected to cut between words, got %q", slug)
+	}
+}
+
+func TestJobPath(t *testing.T) {
+	if path := (Job{ID: "1"}).Path(); path != "/jobs/1" {
+		t.Errorf("expected /jobs/1, got %q", path)
+	}
+
+	if path := (Job{ID: "1", Slug: "café-über"}).Path(); path != "/jobs/1/caf%C3%A9-%C3%BCber" {
+		t.Errorf("expected an escaped slug, got %q", path)
+	}
+}
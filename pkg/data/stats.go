@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// OrganizationCount is how many jobs an organization has posted
+type OrganizationCount struct {
+	Organization string `db:"organization"`
+	Jobs         int    `db:"jobs"`
+}
+
+// CountJobs returns how many jobs are on the board, including any waiting
+// on confirmation or review but not deleted ones
+func CountJobs(ctx context.Context, db *sqlx.DB) (int, error) {
+	var count int
+	err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM jobs WHERE deleted_at IS NULL")
+	return count, err
+}
+
+// CountJobsSince returns how many jobs were posted after since
+func CountJobsSince(ctx context.Context, since time.Time, db *sqlx.DB) (int, error) {
+	var count int
+	err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM jobs WHERE published_at >= $1 AND deleted_at IS NULL", since)
+	return count, err
+}
+
+// CountJobsByOrganization returns how many jobs each organization has
+// posted, most first
+func CountJobsByOrganization(ctx context.Context, db *sqlx.DB) ([]OrganizationCount, error) {
+	var counts []OrganizationCount
+	err := db.SelectContext(ctx, &counts, `SELECT organization, COUNT(*) AS jobs FROM jobs
+		WHERE deleted_at IS NULL GROUP BY organization ORDER BY jobs DESC, organization`)
+	return counts, err
+}
+
+// GetExpiringJobs returns the jobs that will be purged within the next
+// week, soonest first
+func GetExpiringJobs(ctx context.Context, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+	err := db.SelectContext(ctx, &jobs, `SELECT * FROM jobs
+		WHERE published_at >= NOW() - INTERVAL '30 DAYS' AND published_at < NOW() - INTERVAL '23 DAYS'
+		AND deleted_at IS NULL
+		ORDER BY published_at`)
+	return jobs, err
+}
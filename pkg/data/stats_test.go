@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestJobStats(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectQuery(`SELECT COUNT\(\*\) FROM jobs WHERE deleted_at IS NULL$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	total, err := CountJobs(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 12 {
+		t.Errorf("expected 12 jobs, got %d", total)
+	}
+
+	since := time.Now().AddDate(0, 0, -7)
+	dbmock.ExpectQuery(`SELECT COUNT\(\*\) FROM jobs WHERE published_at >= \$1`).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	recent, err := CountJobsSince(ctx, since, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent != 4 {
+		t.Errorf("expected 4 recent jobs, got %d", recent)
+	}
+
+	dbmock.ExpectQuery(`SELECT organization, COUNT\(\*\) AS jobs FROM jobs\s+WHERE deleted_at IS NULL GROUP BY organization`).
+		WillReturnRows(sqlmock.NewRows([]string{"organization", "jobs"}).
+			AddRow("devICT", 3).
+			AddRow("Other Org", 1))
+
+	counts, err := CountJobsByOrganization(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []OrganizationCount{{"devICT", 3}, {"Other Org", 1}}
+	if len(counts) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, counts)
+	}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], counts[i])
+		}
+	}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs\s+WHERE published_at >= NOW\(\) - INTERVAL '30 DAYS'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow("1", "Pos"))
+
+	expiring, err := GetExpiringJobs(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 1 || expiring[0].ID != "1" {
+		t.Errorf("expected job 1 to be expiring, got %v", expiring)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,136 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Subscriber gets a weekly digest of new jobs once they've confirmed their
+// address
+type Subscriber struct {
+	ID           string       `db:"id"`
+	Email        string       `db:"email"`
+	Confirmed    bool         `db:"confirmed"`
+	CreatedAt    time.Time    `db:"created_at"`
+	LastDigestAt sql.NullTime `db:"last_digest_at"`
+}
+
+// NormalizeEmail validates an address, returning it lowercased so each
+// subscriber is only stored once
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", errors.New(ErrNoEmail)
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errors.New(ErrInvalidEmail)
+	}
+
+	return strings.ToLower(email), nil
+}
+
+// Subscribe adds an unconfirmed subscriber, or returns the existing one if
+// the address has already subscribed
+func Subscribe(ctx context.Context, email string, db *sqlx.DB) (Subscriber, error) {
+	var sub Subscriber
+
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return sub, err
+	}
+
+	err = db.GetContext(
+		ctx,
+		&sub,
+		`INSERT INTO subscribers (email) VALUES ($1)
+		ON CONFLICT (email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING *`,
+		email,
+	)
+	return sub, err
+}
+
+// ConfirmSubscriber starts sending an address digests, the first a week
+// from now
+func ConfirmSubscriber(ctx context.Context, email string, db *sqlx.DB) error {
+	_, err := db.ExecContext(
+		ctx,
+		"UPDATE subscribers SET confirmed = true, last_digest_at = NOW() WHERE email = $1 AND NOT confirmed",
+		strings.ToLower(email),
+	)
+	return err
+}
+
+// Unsubscribe removes an address, returning whether it was subscribed
+func Unsubscribe(ctx context.Context, email string, db *sqlx.DB) (bool, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM subscribers WHERE email = $1", strings.ToLower(email))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete subscriber: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected != 0, nil
+}
+
+// GetSubscribersDueDigest returns the confirmed subscribers who haven't
+// had a digest in the last week
+func GetSubscribersDueDigest(ctx context.Context, db *sqlx.DB) ([]Subscriber, error) {
+	var subs []Subscriber
+
+	err := db.SelectContext(
+		ctx,
+		&subs,
+		"SELECT * FROM subscribers WHERE confirmed AND (last_digest_at IS NULL OR last_digest_at < NOW() - INTERVAL '7 DAYS')",
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return subs, err
+	}
+
+	return subs, nil
+}
+
+// MarkDigestSent records when a subscriber was last sent a digest
+func MarkDigestSent(ctx context.Context, id string, sentAt time.Time, db *sqlx.DB) error {
+	_, err := db.ExecContext(ctx, "UPDATE subscribers SET last_digest_at = $1 WHERE id = $2", sentAt, id)
+	return err
+}
+
+// DigestSince is where a subscriber's next digest picks up from: their
+// last one, or a week ago if they've never had one
+func (sub Subscriber) DigestSince(now time.Time) time.Time {
+	if sub.LastDigestAt.Valid {
+		return sub.LastDigestAt.Time
+	}
+	return now.AddDate(0, 0, -7)
+}
+
+// GetJobsPostedSince returns the published jobs posted after since, newest
+// first
+func GetJobsPostedSince(ctx context.Context, since time.Time, db *sqlx.DB) ([]Job, error) {
+	var jobs []Job
+
+	err := db.SelectContext(
+		ctx,
+		&jobs,
+		"SELECT * FROM jobs WHERE published_at > $1 AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL ORDER BY published_at DESC",
+		since,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return jobs, err
+	}
+
+	return jobs, nil
+}
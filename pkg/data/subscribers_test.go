@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+var subscriberColumns = []string{"id", "email", "confirmed", "created_at", "last_digest_at"}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email       string
+		expected    string
+		expectedErr string
+	}{
+		{email: "Test@Example.com", expected: "test@example.com"},
+		{email: "  test@example.com ", expected: "test@example.com"},
+		{email: "", expectedErr: ErrNoEmail},
+		{email: "testexample.com", expectedErr: ErrInvalidEmail},
+		{email: "Test <test@example.com>", expectedErr: ErrInvalidEmail},
+	}
+
+	for _, tt := range tests {
+		email, err := NormalizeEmail(tt.email)
+		if tt.expectedErr != "" {
+			if err == nil || err.Error() != tt.expectedErr {
+				t.Errorf("%q: expected error %q, got %v", tt.email, tt.expectedErr, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.email, err)
+		} else if email != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.email, tt.expected, email)
+		}
+	}
+}
+
+func TestSubscribeAndUnsubscribe(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	dbmock.ExpectQuery(`INSERT INTO subscribers \(email\) VALUES \(\$1\)\s+ON CONFLICT \(email\)`).
+		WithArgs("test@example.com").
+		WillReturnRows(sqlmock.NewRows(subscriberColumns).AddRow("1", "test@example.com", false, time.Now(), nil))
+
+	sub, err := Subscribe(ctx, "Test@Example.com", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID != "1" || sub.Confirmed {
+		t.Errorf("expected unconfirmed subscriber 1, got %+v", sub)
+	}
+
+	// invalid addresses never reach the database
+	if _, err := Subscribe(ctx, "not an email", db); err == nil {
+		t.Error("expected an error subscribing an invalid address")
+	}
+
+	dbmock.ExpectExec(`UPDATE subscribers SET confirmed = true, last_digest_at = NOW\(\) WHERE email = \$1`).
+		WithArgs("test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := ConfirmSubscriber(ctx, "Test@Example.com", db); err != nil {
+		t.Fatal(err)
+	}
+
+	dbmock.ExpectExec(`DELETE FROM subscribers WHERE email = \$1`).
+		WithArgs("test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.ExpectExec(`DELETE FROM subscribers WHERE email = \$1`).
+		WithArgs("test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if removed, err := Unsubscribe(ctx, "test@example.com", db); err != nil || !removed {
+		t.Errorf("expected to unsubscribe, got %t, %v", removed, err)
+	}
+	if removed, err := Unsubscribe(ctx, "test@example.com", db); err != nil || removed {
+		t.Errorf("expected nothing to unsubscribe, got %t, %v", removed, err)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDigestAssembly(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	ctx := context.Background()
+
+	now := time.Now()
+	lastWeek := now.AddDate(0, 0, -8)
+
+	dbmock.ExpectQuery(`SELECT \* FROM subscribers WHERE confirmed AND \(last_digest_at IS NULL OR last_digest_at < NOW\(\) - INTERVAL '7 DAYS'\)`).
+		WillReturnRows(sqlmock.NewRows(subscriberColumns).
+			AddRow("1", "old@example.com", true, now, lastWeek).
+			AddRow("2", "new@example.com", true, now, nil))
+
+	subs, err := GetSubscribersDueDigest(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", len(subs))
+	}
+
+	// Digests pick up where the last one left off, or a week ago
+	if since := subs[0].DigestSince(now); !since.Equal(lastWeek) {
+		t.Errorf("expected digest since %s, got %s", lastWeek, since)
+	}
+	if since := subs[1].DigestSince(now); !since.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("expected digest since a week ago, got %s", since)
+	}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at > \$1 AND confirmed .+ ORDER BY published_at DESC`).
+		WithArgs(lastWeek).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "position"}).AddRow("1", "Pos"))
+
+	jobs, err := GetJobsPostedSince(ctx, lastWeek, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != "1" {
+		t.Errorf("expected job 1, got %v", jobs)
+	}
+
+	dbmock.ExpectExec(`UPDATE subscribers SET last_digest_at = \$1 WHERE id = \$2`).
+		WithArgs(now, "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := MarkDigestSent(ctx, "1", now, db); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dbmock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
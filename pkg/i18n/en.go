@@ -0,0 +1,94 @@
+package i18n
+
+var en = Messages{
+	// Validation errors, returned as keys by data.NewJob.Validate
+	"error.no_position":           "Must provide a Position",
+	"error.no_organization":       "Must provide a Organization",
+	"error.no_email":              "Must provide an Email Address",
+	"error.invalid_url":           "Must provide a valid Url",
+	"error.invalid_email":         "Must provide a valid Email",
+	"error.disposable_email":      "Please use a permanent email address, not a disposable one",
+	"error.no_url_or_description": "Must provide either a Url or a Description",
+	"error.invalid_logo":          "Logo must be a png, jpg, or svg image",
+	"error.logo_too_large":        "Logo must be smaller than 1MB",
+	"error.captcha_failed":        "Please complete the CAPTCHA",
+	"error.invalid_metadata":      "Unsupported custom field",
+	"error.position_too_long":     "Position is too long",
+	"error.organization_too_long": "Organization is too long",
+	"error.url_too_long":          "Url is too long",
+	"error.description_too_long":  "Description is too long",
+	"error.email_too_long":        "Email is too long",
+	"error.metadata_too_long":     "Custom field is too long",
+	"error.position_markup":       "Position can't contain HTML",
+	"error.organization_markup":   "Organization can't contain HTML",
+
+	"flash.duplicate_job":         "Looks like this job was already posted, so we didn't post it again.",
+	"flash.create_failed":         "Error creating job",
+	"flash.confirm_job":           "Almost done! Check your email for a link to confirm your job posting.",
+	"flash.job_submitted":         "Job submitted! It will be published once it has been reviewed.",
+	"flash.job_created":           "Job created!",
+	"flash.rate_limited":          "We've received a lot of posts from you, please wait %d seconds and then submit your job again.",
+	"flash.job_updated":           "Job updated!",
+	"flash.job_already_confirmed": "Job already confirmed!",
+	"flash.job_confirmed":         "Job confirmed!",
+	"flash.job_deleted":           "Job deleted!",
+	"flash.confirm_subscription":  "Almost done! Check your email for a link to confirm your subscription.",
+	"flash.subscribed":            "You're subscribed! Look out for new jobs in your inbox every week.",
+
+	"nav.title":         "Job Board",
+	"nav.post_job":      "Post a job",
+	"footer.made_by":    "Made by",
+	"footer.contribute": "Contribute on GitHub",
+
+	"notfound.title": "Page not found",
+	"notfound.body":  "We couldn't find what you were looking for. If it was a job, it may have expired or been filled.",
+	"notfound.home":  "Back to the job board",
+
+	"servererror.title":      "Something went wrong",
+	"servererror.body":       "Sorry, we couldn't load this page. Please try again in a few minutes.",
+	"servererror.request_id": "If this keeps happening, let us know and include this request ID: %s",
+
+	"time.just_now":    "just now",
+	"time.minute_ago":  "1 minute ago",
+	"time.minutes_ago": "%d minutes ago",
+	"time.hour_ago":    "1 hour ago",
+	"time.hours_ago":   "%d hours ago",
+	"time.day_ago":     "1 day ago",
+	"time.days_ago":    "%d days ago",
+
+	"form.position":          "Position",
+	"form.organization":      "Organization",
+	"form.url":               "URL",
+	"form.description":       "Description",
+	"form.description_help":  "Please provide a description below if no URL is available.",
+	"form.logo":              "Logo",
+	"form.logo_help":         "Optional. A png, jpg, or svg smaller than 1MB.",
+	"form.logo_replace_help": "Optional. A png, jpg, or svg smaller than 1MB to replace the current logo.",
+	"form.current_logo":      "Current logo",
+	"form.apply_count":       "Apply clicks so far: %d",
+	"form.email":             "Email",
+	"form.preview":           "Preview",
+	"form.publish":           "Publish",
+	"form.update":            "Update",
+	"form.delete":            "Delete",
+	"form.cancel":            "Cancel",
+
+	"jobs.sort_by":           "Sort by",
+	"jobs.sort":              "Sort",
+	"jobs.sort_newest":       "Newest",
+	"jobs.sort_oldest":       "Oldest",
+	"jobs.sort_organization": "Organization",
+	"jobs.at_organization":   "Jobs at %s",
+	"jobs.count":             "%d jobs",
+	"jobs.summary_one":       "1 open position, updated %s",
+	"jobs.summary":           "%d open positions, updated %s",
+	"jobs.none_posted":       "No job openings posted.",
+	"jobs.all_employed":      "The software development industry is 100% employed at the moment.",
+	"jobs.subscribe_label":   "Get new jobs in your inbox every week",
+	"jobs.subscribe":         "Subscribe",
+	"jobs.featured":          "Featured",
+	"jobs.posted":            "Posted %s",
+	"jobs.posted_ago":        "Posted %s",
+	"jobs.apply":             "Apply",
+	"jobs.confirm_delete":    "Are you sure you want to delete this job posting? It will be removed from the board; contact an admin to restore it.",
+}
@@ -0,0 +1,93 @@
+package i18n
+
+var es = Messages{
+	"error.no_position":           "Debe indicar un puesto",
+	"error.no_organization":       "Debe indicar una organización",
+	"error.no_email":              "Debe indicar un correo electrónico",
+	"error.invalid_url":           "Debe indicar una URL válida",
+	"error.invalid_email":         "Debe indicar un correo electrónico válido",
+	"error.disposable_email":      "Por favor, use una dirección de correo electrónico permanente, no una desechable",
+	"error.no_url_or_description": "Debe indicar una URL o una descripción",
+	"error.invalid_logo":          "El logotipo debe ser una imagen png, jpg o svg",
+	"error.logo_too_large":        "El logotipo debe pesar menos de 1MB",
+	"error.captcha_failed":        "Por favor, complete el CAPTCHA",
+	"error.invalid_metadata":      "Campo personalizado no admitido",
+	"error.position_too_long":     "El puesto es demasiado largo",
+	"error.organization_too_long": "La organización es demasiado larga",
+	"error.url_too_long":          "La URL es demasiado larga",
+	"error.description_too_long":  "La descripción es demasiado larga",
+	"error.email_too_long":        "El correo electrónico es demasiado largo",
+	"error.metadata_too_long":     "El campo personalizado es demasiado largo",
+	"error.position_markup":       "El puesto no puede contener HTML",
+	"error.organization_markup":   "La organización no puede contener HTML",
+
+	"flash.duplicate_job":         "Parece que este empleo ya se había publicado, así que no lo publicamos otra vez.",
+	"flash.create_failed":         "Error al crear el empleo",
+	"flash.confirm_job":           "¡Casi listo! Revise su correo para encontrar el enlace que confirma su publicación.",
+	"flash.job_submitted":         "¡Empleo enviado! Se publicará una vez que haya sido revisado.",
+	"flash.job_created":           "¡Empleo creado!",
+	"flash.rate_limited":          "Hemos recibido muchas publicaciones suyas, espere %d segundos y vuelva a enviar su empleo.",
+	"flash.job_updated":           "¡Empleo actualizado!",
+	"flash.job_already_confirmed": "¡El empleo ya estaba confirmado!",
+	"flash.job_confirmed":         "¡Empleo confirmado!",
+	"flash.job_deleted":           "¡Empleo eliminado!",
+	"flash.confirm_subscription":  "¡Casi listo! Revise su correo para encontrar el enlace que confirma su suscripción.",
+	"flash.subscribed":            "¡Ya está suscrito! Recibirá los empleos nuevos en su correo cada semana.",
+
+	"nav.title":         "Bolsa de Trabajo",
+	"nav.post_job":      "Publicar un empleo",
+	"footer.made_by":    "Hecho por",
+	"footer.contribute": "Contribuya en GitHub",
+
+	"notfound.title": "Página no encontrada",
+	"notfound.body":  "No pudimos encontrar lo que buscaba. Si era un empleo, puede que haya vencido o ya se haya ocupado.",
+	"notfound.home":  "Volver a la bolsa de trabajo",
+
+	"servererror.title":      "Algo salió mal",
+	"servererror.body":       "Lo sentimos, no pudimos cargar esta página. Por favor, inténtelo de nuevo en unos minutos.",
+	"servererror.request_id": "Si esto sigue ocurriendo, avísenos e incluya este ID de solicitud: %s",
+
+	"time.just_now":    "hace un momento",
+	"time.minute_ago":  "hace 1 minuto",
+	"time.minutes_ago": "hace %d minutos",
+	"time.hour_ago":    "hace 1 hora",
+	"time.hours_ago":   "hace %d horas",
+	"time.day_ago":     "hace 1 día",
+	"time.days_ago":    "hace %d días",
+
+	"form.position":          "Puesto",
+	"form.organization":      "Organización",
+	"form.url":               "URL",
+	"form.description":       "Descripción",
+	"form.description_help":  "Si no hay una URL disponible, escriba una descripción a continuación.",
+	"form.logo":              "Logotipo",
+	"form.logo_help":         "Opcional. Un png, jpg o svg de menos de 1MB.",
+	"form.logo_replace_help": "Opcional. Un png, jpg o svg de menos de 1MB para reemplazar el logotipo actual.",
+	"form.current_logo":      "Logotipo actual",
+	"form.apply_count":       "Clics en Postularse hasta ahora: %d",
+	"form.email":             "Correo electrónico",
+	"form.preview":           "Vista previa",
+	"form.publish":           "Publicar",
+	"form.update":            "Actualizar",
+	"form.delete":            "Eliminar",
+	"form.cancel":            "Cancelar",
+
+	"jobs.sort_by":           "Ordenar por",
+	"jobs.sort":              "Ordenar",
+	"jobs.sort_newest":       "Más recientes",
+	"jobs.sort_oldest":       "Más antiguos",
+	"jobs.sort_organization": "Organización",
+	"jobs.at_organization":   "Empleos en %s",
+	"jobs.count":             "%d empleos",
+	"jobs.summary_one":       "1 puesto abierto, actualizado %s",
+	"jobs.summary":           "%d puestos abiertos, actualizado %s",
+	"jobs.none_posted":       "No hay vacantes publicadas.",
+	"jobs.all_employed":      "La industria del desarrollo de software tiene pleno empleo en este momento.",
+	"jobs.subscribe_label":   "Reciba los empleos nuevos en su correo cada semana",
+	"jobs.subscribe":         "Suscribirse",
+	"jobs.featured":          "Destacado",
+	"jobs.posted":            "Publicado el %s",
+	"jobs.posted_ago":        "Publicado %s",
+	"jobs.apply":             "Postularse",
+	"jobs.confirm_delete":    "¿Seguro que desea eliminar esta publicación? Se quitará del sitio; contacte a un administrador para restaurarla.",
+}
@@ -0,0 +1,106 @@
+// Package i18n holds the text shown to visitors in each supported language.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is used when a visitor doesn't ask for a language we have,
+// and for any message missing from the one they asked for
+const DefaultLocale = "en"
+
+// Messages maps message keys to their text in one language. Text can have
+// fmt verbs, filled in by the args given to Translate.
+type Messages map[string]string
+
+var catalog = map[string]Messages{
+	"en": en,
+	"es": es,
+}
+
+// Translate returns the text for key in locale, falling back to the
+// default locale, then to the key itself so a missing message is obvious
+// rather than blank
+func Translate(locale, key string, args ...interface{}) string {
+	msg, ok := catalog[locale][key]
+	if !ok {
+		msg, ok = catalog[DefaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+
+	if len(args) != 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// FromAcceptLanguage picks the supported locale the visitor prefers most
+// from an Accept-Language header. Regional variants match their language,
+// so es-MX gets es.
+func FromAcceptLanguage(header string) string {
+	type preference struct {
+		locale string
+		q      float64
+	}
+
+	var prefs []preference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		locale := strings.ToLower(strings.TrimSpace(tag))
+		if _, ok := catalog[locale]; !ok {
+			locale, _, _ = strings.Cut(locale, "-")
+		}
+		if _, ok := catalog[locale]; ok && q > 0 {
+			prefs = append(prefs, preference{locale, q})
+		}
+	}
+
+	if len(prefs) == 0 {
+		return DefaultLocale
+	}
+
+	// Stable so equally weighted languages keep the visitor's order
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	return prefs[0].locale
+}
+
+// TimeAgo describes how long before now t was, e.g. "5 days ago", in
+// whole minutes, hours or days. Anything under a minute, or in the future,
+// is "just now".
+func TimeAgo(locale string, t, now time.Time) string {
+	elapsed := now.Sub(t)
+
+	count := func(n int, one, many string) string {
+		if n == 1 {
+			return Translate(locale, one)
+		}
+		return Translate(locale, many, n)
+	}
+
+	switch {
+	case elapsed < time.Minute:
+		return Translate(locale, "time.just_now")
+	case elapsed < time.Hour:
+		return count(int(elapsed/time.Minute), "time.minute_ago", "time.minutes_ago")
+	case elapsed < 24*time.Hour:
+		return count(int(elapsed/time.Hour), "time.hour_ago", "time.hours_ago")
+	default:
+		return count(int(elapsed/(24*time.Hour)), "time.day_ago", "time.days_ago")
+	}
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: "en"},
+		{header: "es", expected: "es"},
+		{header: "ES-mx", expected: "es"},
+		{header: "fr-FR, es;q=0.8, en;q=0.5", expected: "es"},
+		{header: "en;q=0.5, es;q=0.9", expected: "es"},
+		{header: "en, es", expected: "en"},
+		{header: "es;q=0", expected: "en"},
+		{header: "es;q=lots, en", expected: "en"},
+		{header: "fr, de", expected: "en"},
+		{header: "*", expected: "en"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, FromAcceptLanguage(tt.header), tt.header)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "Job created!", Translate("en", "flash.job_created"))
+	assert.Equal(t, "¡Empleo creado!", Translate("es", "flash.job_created"))
+	assert.Equal(t, "Publicado el 2022/02/02", Translate("es", "jobs.posted", "2022/02/02"))
+
+	// Unknown locales and keys fall back rather than rendering nothing
+	assert.Equal(t, "Job created!", Translate("fr", "flash.job_created"))
+	assert.Equal(t, "flash.nonsense", Translate("es", "flash.nonsense"))
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for locale, messages := range catalog {
+		for key := range catalog[DefaultLocale] {
+			assert.Contains(t, messages, key, "%s is missing %s", locale, key)
+		}
+		for key := range messages {
+			assert.Contains(t, catalog[DefaultLocale], key, "%s has unknown key %s", locale, key)
+		}
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2022, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		ago      time.Duration
+		expected string
+	}{
+		{ago: -time.Minute, expected: "just now"},
+		{ago: 0, expected: "just now"},
+		{ago: 59 * time.Second, expected: "just now"},
+		{ago: time.Minute, expected: "1 minute ago"},
+		{ago: 59*time.Minute + 59*time.Second, expected: "59 minutes ago"},
+		{ago: time.Hour, expected: "1 hour ago"},
+		{ago: 2 * time.Hour, expected: "2 hours ago"},
+		{ago: 23*time.Hour + 59*time.Minute, expected: "23 hours ago"},
+		{ago: 24 * time.Hour, expected: "1 day ago"},
+		{ago: 5*24*time.Hour + 23*time.Hour, expected: "5 days ago"},
+		{ago: 30 * 24 * time.Hour, expected: "30 days ago"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, TimeAgo("en", now.Add(-tt.ago), now), tt.ago)
+	}
+
+	assert.Equal(t, "hace un momento", TimeAgo("es", now, now))
+	assert.Equal(t, "hace 1 día", TimeAgo("es", now.Add(-24*time.Hour), now))
+	assert.Equal(t, "hace 3 horas", TimeAgo("es", now.Add(-3*time.Hour), now))
+}
@@ -0,0 +1,86 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Purger runs Purge every Interval. After consecutive failures it waits
+// twice as long each time, up to MaxBackoff, so a persistent error
+// doesn't spam the log; the first success resets it.
+type Purger struct {
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Purge      func() error
+
+	failures int64
+	// wait sleeps for d, returning false if ctx is done first
+	wait func(ctx context.Context, d time.Duration) bool
+}
+
+// Failures is the total number of failed purges
+func (p *Purger) Failures() int64 {
+	return atomic.LoadInt64(&p.failures)
+}
+
+// StartPurgeLoop runs the purger in the background until ctx is done.
+// The returned channel is closed once it has stopped.
+func StartPurgeLoop(ctx context.Context, p *Purger) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.run(ctx)
+		log.Println("shutting down old jobs background process")
+	}()
+	return done
+}
+
+func (p *Purger) run(ctx context.Context) {
+	wait := p.wait
+	if wait == nil {
+		wait = sleep
+	}
+
+	consecutive := 0
+	for {
+		if err := p.Purge(); err != nil {
+			consecutive++
+			total := atomic.AddInt64(&p.failures, 1)
+			log.Println(fmt.Errorf("error clearing old jobs (%d in a row, %d total): %w", consecutive, total, err))
+		} else {
+			consecutive = 0
+		}
+
+		if !wait(ctx, p.delay(consecutive)) {
+			return
+		}
+	}
+}
+
+// delay doubles the interval for each consecutive failure, capped at
+// MaxBackoff.
+func (p *Purger) delay(consecutive int) time.Duration {
+	d := p.Interval
+	for i := 0; i < consecutive; i++ {
+		if d >= p.MaxBackoff/2 {
+			return p.MaxBackoff
+		}
+		d *= 2
+	}
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,74 @@
+package purge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgerBackoff(t *testing.T) {
+	results := []error{
+		fmt.Errorf("db down"),
+		fmt.Errorf("db down"),
+		fmt.Errorf("db down"),
+		fmt.Errorf("db down"),
+		fmt.Errorf("db down"),
+		nil,
+		fmt.Errorf("db down"),
+	}
+
+	var delays []time.Duration
+	p := &Purger{
+		Interval:   time.Hour,
+		MaxBackoff: 12 * time.Hour,
+		Purge: func() error {
+			err := results[0]
+			results = results[1:]
+			return err
+		},
+		wait: func(ctx context.Context, d time.Duration) bool {
+			delays = append(delays, d)
+			return len(results) > 0
+		},
+	}
+
+	<-StartPurgeLoop(context.Background(), p)
+
+	assert.Equal(t, []time.Duration{
+		2 * time.Hour,
+		4 * time.Hour,
+		8 * time.Hour,
+		12 * time.Hour, // capped
+		12 * time.Hour,
+		time.Hour, // reset by the success
+		2 * time.Hour,
+	}, delays)
+	assert.Equal(t, int64(6), p.Failures())
+}
+
+func TestStartPurgeLoopStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	purged := make(chan struct{}, 1)
+	p := &Purger{
+		Interval:   time.Hour,
+		MaxBackoff: time.Hour,
+		Purge: func() error {
+			purged <- struct{}{}
+			return nil
+		},
+	}
+
+	done := StartPurgeLoop(ctx, p)
+	<-purged
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purge loop did not stop")
+	}
+}
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/services"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+func (ctrl *Controller) AdminIndex(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	pending, err := data.GetPendingJobs(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to getPendingJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	published, err := data.GetAllJobs(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to getAllJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	total, err := data.CountJobs(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to countJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	lastWeek, err := data.CountJobsSince(dbCtx, now.AddDate(0, 0, -7), ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to countJobsSince: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	lastMonth, err := data.CountJobsSince(dbCtx, now.AddDate(0, 0, -30), ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to countJobsSince: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	byOrganization, err := data.CountJobsByOrganization(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to countJobsByOrganization: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	expiring, err := data.GetExpiringJobs(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to getExpiringJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	deleted, err := data.GetDeletedJobs(dbCtx, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to getDeletedJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	auditLog, err := data.GetAuditLog(dbCtx, auditLogSize, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("AdminIndex failed to getAuditLog: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.HTML(200, "admin", addFlash(ctx, gin.H{
+		"pending":        pending,
+		"published":      published,
+		"total":          total,
+		"lastWeek":       lastWeek,
+		"lastMonth":      lastMonth,
+		"byOrganization": byOrganization,
+		"expiring":       expiring,
+		"deleted":        deleted,
+		"auditLog":       auditLog,
+	}))
+}
+
+func (ctrl *Controller) RestoreJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("RestoreJob failed to session.Save: %w", err))
+		}
+	}()
+
+	if err := data.RestoreJob(dbCtx, id, ctrl.DB); err != nil {
+		log.Println(fmt.Errorf("failed to restoreJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctrl.JobList.Invalidate()
+	ctrl.recordAudit(ctx, dbCtx, id, data.AuditRestore)
+
+	flash(session, flashSuccess, "Job restored!")
+	ctx.Redirect(302, "/admin")
+}
+
+func (ctrl *Controller) ToggleFeaturedJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("ToggleFeaturedJob failed to session.Save: %w", err))
+		}
+	}()
+
+	featured, err := data.ToggleJobFeatured(dbCtx, id, ctrl.DB)
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("failed to toggleJobFeatured: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctrl.JobList.Invalidate()
+
+	if featured {
+		ctrl.recordAudit(ctx, dbCtx, id, data.AuditFeature)
+		flash(session, flashSuccess, "Job featured!")
+	} else {
+		ctrl.recordAudit(ctx, dbCtx, id, data.AuditUnfeature)
+		flash(session, flashSuccess, "Job no longer featured!")
+	}
+	ctx.Redirect(302, "/admin")
+}
+
+func (ctrl *Controller) JobRevisions(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("JobRevisions failed to getJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := data.GetJobRevisions(dbCtx, id, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("JobRevisions failed to getJobRevisions: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.HTML(200, "revisions", addFlash(ctx, gin.H{
+		"job":       job,
+		"revisions": revisions,
+	}))
+}
+
+// RevertJob puts a job back how it was at a revision. The version being
+// replaced is kept as a revision too, so reverting can itself be undone.
+func (ctrl *Controller) RevertJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("RevertJob failed to session.Save: %w", err))
+		}
+	}()
+
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("RevertJob failed to getJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	revision, err := data.GetJobRevision(dbCtx, id, ctx.Param("revision"), ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to getJobRevision: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if revision.ID == "" {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	revision.Apply(&job)
+	if _, err := job.Save(dbCtx, ctrl.DB, ctrl.Config.MaxJobRevisions); err != nil {
+		log.Println(fmt.Errorf("RevertJob failed to job.save: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctrl.JobList.Invalidate()
+	ctrl.recordAudit(ctx, dbCtx, id, data.AuditRevert)
+
+	flash(session, flashSuccess, "Job reverted!")
+	ctx.Redirect(302, fmt.Sprintf("/admin/jobs/%s/revisions", id))
+}
+
+func (ctrl *Controller) ApproveJob(ctx *gin.Context) {
+	ctrl.moderateJob(ctx, data.StatusApproved)
+}
+
+func (ctrl *Controller) RejectJob(ctx *gin.Context) {
+	ctrl.moderateJob(ctx, data.StatusRejected)
+}
+
+func (ctrl *Controller) moderateJob(ctx *gin.Context, status string) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("moderateJob failed to session.Save: %w", err))
+		}
+	}()
+
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("moderateJob failed to getJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Jobs held for review can be approved or rejected whatever their
+	// status, since that's what clears the hold
+	if job.NeedsReview {
+		if err := data.ReviewJob(dbCtx, id, status, ctrl.DB); err != nil {
+			log.Println(fmt.Errorf("failed to reviewJob: %w", err))
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	} else if !data.ValidTransition(job.Status, status) {
+		flash(session, flashInfo, fmt.Sprintf("Job already %s!", job.Status))
+		ctx.Redirect(302, "/admin")
+		return
+	} else if err := data.SetJobStatus(dbCtx, id, job.Status, status, ctrl.DB); err != nil {
+		log.Println(fmt.Errorf("failed to setJobStatus: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	action := data.AuditApprove
+	if status == data.StatusRejected {
+		action = data.AuditReject
+	}
+	ctrl.JobList.Invalidate()
+	ctrl.recordAudit(ctx, dbCtx, id, action)
+
+	// Jobs are only announced the first time they're approved, and ones
+	// held for review never were
+	announce := job.Status == data.StatusPending || job.NeedsReview
+	job.Status = status
+	job.NeedsReview = false
+	ctrl.notify(func() { ctrl.notifyJobModerated(job, announce) })
+
+	flash(session, flashSuccess, fmt.Sprintf("Job %s!", status))
+	ctx.Redirect(302, "/admin")
+}
+
+// notifyJobModerated lets the poster know whether their job was approved,
+// and announces it if it's now published.
+func (ctrl *Controller) notifyJobModerated(job data.Job, announce bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget)
+	defer cancel()
+
+	if ctrl.EmailService != nil {
+		subject := "Job Approved!"
+		message := fmt.Sprintf(
+			"Your job posting has been approved and is now live!\n\n<a href=\"%s%s\">View the job posting</a>",
+			ctrl.Config.URL,
+			job.Path(),
+		)
+		if job.Status == data.StatusRejected {
+			subject = "Job Rejected"
+			message = fmt.Sprintf("Sorry, your posting for %s wasn't approved for the job board.", html.EscapeString(job.Position))
+		} else if !job.IsPublished() {
+			// Approved before the poster confirmed it
+			message = fmt.Sprintf(
+				"Your job posting has been approved, and will go live once you confirm it.\n\n<a href=\"%s\">Confirm and publish the job posting</a>",
+				SignedConfirmRoute(job, ctrl.Config),
+			)
+		}
+
+		err := services.Retry(ctx, notifyAttempts, notifyBaseDelay, func() error {
+			return ctrl.EmailService.SendEmail(job.Email, subject, message)
+		})
+		if err != nil {
+			log.Println(fmt.Errorf("failed to sendEmail: %w", err))
+			// continuing...
+		}
+	}
+
+	if announce && job.IsPublished() {
+		ctrl.announceJob(ctx, job)
+	}
+}
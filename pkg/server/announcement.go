@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// announcementKey is where announce leaves the banner for addFlash to pass
+// to the templates
+const announcementKey = "announcement"
+
+// dismissedKey holds the text of the announcement the session dismissed,
+// so posting a new one shows the banner again
+const dismissedKey = "announcement_dismissed"
+
+type announcement struct {
+	Text string
+	URL  string
+
+	// Next is where to go after dismissing it, i.e. back to this page
+	Next string
+}
+
+// announce shows the banner on every page unless this session has
+// dismissed it.
+func announce(text, url string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if text == "" {
+			return
+		}
+
+		if sessions.Default(ctx).Get(dismissedKey) == text {
+			return
+		}
+
+		ctx.Set(announcementKey, announcement{
+			Text: text,
+			URL:  url,
+			Next: ctx.Request.URL.RequestURI(),
+		})
+	}
+}
+
+func (ctrl *Controller) DismissAnnouncement(ctx *gin.Context) {
+	session := sessions.Default(ctx)
+	session.Set(dismissedKey, ctrl.Config.AnnouncementText)
+	session.Save()
+
+	ctx.Redirect(http.StatusFound, localRedirect(ctx.PostForm("next")))
+}
+
+// localRedirect only allows redirecting to paths on this site, falling
+// back to the index.
+func localRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
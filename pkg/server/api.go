@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-gonic/gin"
+)
+
+// apiJob is the JSON body of POST /api/v1/jobs. It's kept apart from
+// data.NewJob so a request can't set the fields the server decides.
+type apiJob struct {
+	Position     string            `json:"position"`
+	Organization string            `json:"organization"`
+	Url          string            `json:"url"`
+	Description  string            `json:"description"`
+	Email        string            `json:"email"`
+	Metadata     map[string]string `json:"metadata"`
+}
+
+// apiErrorBody is what every /api error responds with, as
+// {"error": {"code": "...", "message": "..."}}, so clients can handle them
+// the same way. Code is stable for clients to check, and message is for
+// people.
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields has the validation message for each invalid field
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// apiError aborts with the API's error envelope
+func apiError(ctx *gin.Context, status int, code, message string) {
+	ctx.AbortWithStatusJSON(status, gin.H{"error": apiErrorBody{Code: code, Message: message}})
+}
+
+// isAPIRequest reports whether the request is for the JSON API, so errors
+// are sent as JSON rather than pages
+func isAPIRequest(ctx *gin.Context) bool {
+	path := ctx.Request.URL.Path
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
+
+// deprecatedFor marks responses from an old API path as deprecated, with a
+// link to the path that replaced it
+func deprecatedFor(successor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+	}
+}
+
+// APICreateJob creates a job for partners posting from their own systems.
+// It skips the CAPTCHA, since the caller has an API key, but jobs go
+// through the same validation, duplicate checks, confirmation and approval
+// as ones posted through the form.
+func (ctrl *Controller) APICreateJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	var input apiJob
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		apiError(ctx, http.StatusBadRequest, "bad_request", fmt.Sprintf("invalid request body: %s", err))
+		return
+	}
+
+	newJobInput := data.NewJob{
+		Position:     input.Position,
+		Organization: input.Organization,
+		Url:          input.Url,
+		Description:  input.Description,
+		Email:        input.Email,
+		Metadata:     data.Metadata(input.Metadata),
+	}
+
+	if errs := newJobInput.Validate(false, ctrl.Config.AllowedMetadataKeys, ctrl.limits(), ctrl.BlockedDomains); len(errs) != 0 {
+		messages := make(map[string]string, len(errs))
+		for k, v := range errs {
+			messages[k] = translate(ctx, v)
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": apiErrorBody{
+			Code:    "invalid_job",
+			Message: "the job has invalid fields",
+			Fields:  messages,
+		}})
+		return
+	}
+
+	if ctrl.Config.DuplicateWindow > 0 {
+		existing, err := data.FindRecentDuplicate(dbCtx, newJobInput, ctrl.Config.DuplicateWindow, ctrl.DB)
+		if err != nil {
+			log.Println(fmt.Errorf("APICreateJob failed to check for duplicate job: %w", err))
+			// continuing...
+		} else if existing.ID != "" {
+			ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": apiErrorBody{Code: "duplicate_job", Message: "this job was already posted"},
+				"job":   existing,
+			})
+			return
+		}
+	}
+
+	newJobInput.Confirmed = !ctrl.Config.RequireConfirmation
+	if ctrl.Config.RequireApproval {
+		newJobInput.Status = data.StatusPending
+	}
+
+	job, err := newJobInput.SaveToDB(dbCtx, ctrl.DB, ctrl.Config.DuplicateSimilarityThreshold)
+	if err != nil {
+		log.Println(fmt.Errorf("APICreateJob failed to save job to db: %w", err))
+		apiError(ctx, http.StatusInternalServerError, "internal_error", "something went wrong")
+		return
+	}
+	ctrl.JobList.Invalidate()
+
+	if job.Confirmed {
+		ctrl.notify(func() { ctrl.notifyJobCreated(job) })
+	} else {
+		ctrl.notify(func() { ctrl.sendConfirmation(job) })
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"job": job, "edit_url": SignedJobRoute(job, ctrl.Config)})
+}
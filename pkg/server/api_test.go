@@ -0,0 +1,269 @@
+package server_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendAPIRequest(t *testing.T, url, key string, body interface{}) (map[string]interface{}, *http.Response) {
+	payload, err := json.Marshal(body)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	if len(respBody) != 0 && resp.Header.Get("Content-Type") == "application/json; charset=utf-8" {
+		assert.NoError(t, json.Unmarshal(respBody, &decoded))
+	}
+	return decoded, resp
+}
+
+func TestAPICreateJob(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:   "sup",
+		Env:         "debug",
+		APIKeys:     []string{"partner-key", "other-key"},
+		NotifyEmail: true,
+		NotifySlack: true,
+	})
+	defer s.Close()
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Email:        "test@example.com",
+		PublishedAt:  time.Now(),
+		Confirmed:    true,
+		Status:       data.StatusApproved,
+	}
+	dbmock.ExpectQuery(`INSERT INTO jobs`).
+		WithArgs("Pos", "Org", "https://devict.org", nil, "test@example.com", false, nil, true, sqlmock.AnyArg(), data.StatusApproved, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(job)...))
+
+	body, resp := sendAPIRequest(t, s.URL+"/api/v1/jobs", "other-key", map[string]interface{}{
+		"position":     "  Pos ",
+		"organization": "Org",
+		"url":          "https://devict.org",
+		"email":        "test@example.com",
+		// Fields the server decides are ignored
+		"confirmed": false,
+		"logo_url":  "https://example.com/logo.png",
+	})
+	svcmock.flush()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, server.SignedJobRoute(job, conf), body["edit_url"])
+	if created, ok := body["job"].(map[string]interface{}); assert.True(t, ok) {
+		assert.Equal(t, "1", created["id"])
+		assert.Equal(t, "Pos", created["position"])
+		assert.NotContains(t, created, "email")
+	}
+
+	// There's no CAPTCHA, but everything else happens as it would for the form
+	assert.Len(t, svcmock.emails, 1)
+	assert.Len(t, svcmock.slacks, 1)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAPICreateJobInvalid(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", APIKeys: []string{"partner-key"}})
+	defer s.Close()
+
+	body, resp := sendAPIRequest(t, s.URL+"/api/v1/jobs", "partner-key", map[string]interface{}{
+		"organization": "Org",
+		"url":          "https://devict.org",
+		"email":        "someone@mailinator.com",
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assertAPIError(t, body, "invalid_job")
+	assert.Equal(t, map[string]interface{}{
+		"position": "Must provide a Position",
+		"email":    "Please use a permanent email address, not a disposable one",
+	}, body["error"].(map[string]interface{})["fields"])
+
+	body, resp = sendAPIRequest(t, s.URL+"/api/v1/jobs", "partner-key", "not a job")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assertAPIError(t, body, "bad_request")
+
+	assert.Empty(t, svcmock.emails)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAPILegacyPath(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", APIKeys: []string{"partner-key"}})
+	defer s.Close()
+
+	v1Body, v1Resp := sendAPIRequest(t, s.URL+"/api/v1/jobs", "partner-key", "not a job")
+	legacyBody, legacyResp := sendAPIRequest(t, s.URL+"/api/jobs", "partner-key", "not a job")
+
+	// The old path still works the same, but says it's going away
+	assert.Equal(t, http.StatusBadRequest, legacyResp.StatusCode)
+	assert.Equal(t, v1Body, legacyBody)
+	assert.Empty(t, v1Resp.Header.Get("Deprecation"))
+	assert.Equal(t, "true", legacyResp.Header.Get("Deprecation"))
+	assert.Equal(t, `</api/v1/jobs>; rel="successor-version"`, legacyResp.Header.Get("Link"))
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAPICreateJobUnauthorized(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", APIKeys: []string{"partner-key"}})
+	defer s.Close()
+
+	job := map[string]interface{}{"position": "Pos", "organization": "Org", "url": "https://devict.org", "email": "test@example.com"}
+
+	for _, key := range []string{"", "wrong-key", "partner-key-2"} {
+		body, resp := sendAPIRequest(t, s.URL+"/api/v1/jobs", key, job)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, key)
+		assertAPIError(t, body, "unauthorized")
+		assert.Equal(t, `Bearer realm="api"`, resp.Header.Get("WWW-Authenticate"), key)
+	}
+
+	// Basic auth with the key as the password isn't accepted either
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/api/v1/jobs", nil)
+	assert.NoError(t, err)
+	req.SetBasicAuth("partner", "partner-key")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAPIErrors(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", APIKeys: []string{"partner-key"}})
+	defer s.Close()
+
+	body, resp := sendAPIRequest(t, s.URL+"/api/nope", "partner-key", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assertAPIError(t, body, "not_found")
+
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnError(errors.New("connection refused"))
+
+	body, resp = sendAPIRequest(t, s.URL+"/api/v1/jobs", "partner-key", map[string]interface{}{
+		"position":     "Pos",
+		"organization": "Org",
+		"url":          "https://devict.org",
+		"email":        "test@example.com",
+	})
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assertAPIError(t, body, "internal_error")
+	assert.NotContains(t, body["error"].(map[string]interface{})["message"], "connection refused")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Pages outside the API are unchanged
+	_, resp = sendRequest(t, s.URL+"/nope", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+}
+
+// assertAPIError checks body is the API's error envelope with code
+func assertAPIError(t *testing.T, body map[string]interface{}, code string) {
+	t.Helper()
+
+	envelope, ok := body["error"].(map[string]interface{})
+	if !assert.True(t, ok, "error envelope in %v", body) {
+		return
+	}
+	assert.Equal(t, code, envelope["code"])
+	assert.NotEmpty(t, envelope["message"])
+}
+
+func TestAPIDisabled(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	_, resp := sendAPIRequest(t, s.URL+"/api/v1/jobs", "partner-key", map[string]interface{}{"position": "Pos"})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPICORS(t *testing.T) {
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:  "sup",
+		Env:        "debug",
+		APIKeys:    []string{"partner-key"},
+		APIOrigins: []string{"https://partner.example"},
+	})
+	defer s.Close()
+
+	preflight := func(origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, s.URL+"/api/v1/jobs", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Preflights for posting JSON jobs are answered without a key
+	resp := preflight("https://partner.example")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://partner.example", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Content-Type")
+	assert.Equal(t, "Origin", resp.Header.Get("Vary"))
+
+	resp = preflight("https://elsewhere.example")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Methods"))
+
+	// Other requests still need a key, and only allowed origins get the header
+	for origin, expect := range map[string]string{"https://partner.example": "https://partner.example", "https://elsewhere.example": ""} {
+		req, err := http.NewRequest(http.MethodPost, s.URL+"/api/v1/jobs", nil)
+		assert.NoError(t, err)
+		req.Header.Set("Origin", origin)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, origin)
+		assert.Equal(t, expect, resp.Header.Get("Access-Control-Allow-Origin"), origin)
+	}
+}
+
+func TestAPICORSDisabled(t *testing.T) {
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", APIKeys: []string{"partner-key"}})
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodOptions, s.URL+"/api/v1/jobs", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Origin", "https://partner.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
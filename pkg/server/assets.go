@@ -0,0 +1,69 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAssetPath is where assets are served from when the server config
+// doesn't say
+const defaultAssetPath = "assets"
+
+// cacheAssets lets browsers keep static assets for maxAge rather than
+// refetching them on every page. Zero or less disables.
+func cacheAssets(maxAge time.Duration) gin.HandlerFunc {
+	if maxAge <= 0 {
+		return func(ctx *gin.Context) {}
+	}
+
+	value := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", value)
+	}
+}
+
+// assetURL returns the url of the asset at name within dir, with a version
+// taken from its contents so cached copies are replaced when it changes.
+// Versions are worked out once per asset, the first time it's asked for.
+func assetURL(dir string) func(name string) string {
+	var versions sync.Map
+
+	return func(name string) string {
+		name = strings.TrimPrefix(name, "/")
+		url := "/assets/" + name
+
+		if v, ok := versions.Load(name); ok {
+			return url + v.(string)
+		}
+
+		version := ""
+		if b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			version = fmt.Sprintf("?v=%x", sha256.Sum256(b))[:15]
+		}
+		versions.Store(name, version)
+
+		return url + version
+	}
+}
+
+// favicon serves favicon.ico from the assets, or an empty response when
+// there isn't one, since browsers ask for it whether or not it's linked
+func favicon(dir string) gin.HandlerFunc {
+	path := filepath.Join(dir, "favicon.ico")
+
+	return func(ctx *gin.Context) {
+		if _, err := os.Stat(path); err != nil {
+			ctx.Status(http.StatusNoContent)
+			return
+		}
+		ctx.File(path)
+	}
+}
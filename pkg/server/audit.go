@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-gonic/gin"
+)
+
+// auditLogSize is how many of the latest entries the admin page shows
+const auditLogSize = 50
+
+// recordAudit notes who changed a job. Admin routes are behind basic auth,
+// so anyone else got here with the job's signed token. It's written after
+// the change has been made, so a failure is logged rather than undoing it.
+func (ctrl *Controller) recordAudit(ctx *gin.Context, dbCtx context.Context, jobID, action string) {
+	actor := ctx.GetString(gin.AuthUserKey)
+	if actor == "" {
+		actor = data.ActorPoster
+	}
+
+	if err := data.RecordAudit(dbCtx, jobID, actor, action, ctrl.DB); err != nil {
+		log.Println(fmt.Errorf("failed to recordAudit: %w", err))
+		// continuing...
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// setCacheHeaders tags a page with a hash of the jobs it shows, in the
+// language it's shown in, and how long ago they were posted. Pages also
+// carry per-session flashes, so clients must revalidate them every time.
+// It reports whether the client's copy is still current, in which case a
+// 304 has been sent and there's nothing left to render.
+func setCacheHeaders(ctx *gin.Context, jobs ...data.Job) bool {
+	h := sha256.New()
+	for _, job := range jobs {
+		fmt.Fprintf(h, "%+v %s\n", job, timeAgo(ctx.GetString(localeKey), job.PublishedAt))
+	}
+	if a, ok := ctx.Get(announcementKey); ok {
+		fmt.Fprintf(h, "%+v\n", a)
+	}
+	fmt.Fprintln(h, ctx.GetString(localeKey), ctx.GetString(sortKey))
+
+	etag := fmt.Sprintf(`W/"%x"`, h.Sum(nil)[:16])
+	ctx.Header("Cache-Control", "private, no-cache")
+	ctx.Header("Vary", "Accept-Language")
+	ctx.Header("ETag", etag)
+
+	// A flash waiting to be shown isn't part of the tag, so the page has
+	// to be rendered for it
+	if !etagMatches(ctx.GetHeader("If-None-Match"), etag) || hasFlashes(ctx) {
+		return false
+	}
+
+	ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. The
+// comparison is weak, as it always is for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlashes reports whether the session has flash messages waiting, without
+// clearing them
+func hasFlashes(ctx *gin.Context) bool {
+	return sessions.Default(ctx).Get(flashesKey) != nil
+}
+
+// isHead reports whether only headers were asked for, so handlers can skip
+// rendering a body that would be thrown away.
+func isHead(ctx *gin.Context) bool {
+	return ctx.Request.Method == http.MethodHead
+}
+
+// JobListCache keeps the published job list in memory for TTL, so traffic
+// spikes on the index don't each query the database. Handlers that change
+// jobs Invalidate it; changes made elsewhere, like the purge loop or
+// another instance, show up once the TTL runs out. A nil JobListCache
+// always queries the database.
+type JobListCache struct {
+	TTL time.Duration
+	// Timeout bounds each query, which isn't tied to any one request.
+	// Zero disables.
+	Timeout time.Duration
+
+	mu    sync.Mutex
+	lists map[string]cachedJobList
+	loads map[string]*jobListLoad
+	// generation is bumped by Invalidate, so loads that started before it
+	// aren't cached
+	generation int
+}
+
+type cachedJobList struct {
+	jobs    []data.Job
+	expires time.Time
+}
+
+// jobListLoad is a query in progress, which every Get for its sort waits on
+type jobListLoad struct {
+	done chan struct{}
+	jobs []data.Job
+	err  error
+}
+
+// NewJobListCache returns a cache holding lists for ttl, with queries
+// limited to timeout, or nil if ttl isn't positive
+func NewJobListCache(ttl, timeout time.Duration) *JobListCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &JobListCache{TTL: ttl, Timeout: timeout}
+}
+
+// Get returns the published jobs in the given order, querying db if
+// there's no fresh copy. Concurrent misses for a sort wait on a single
+// query rather than all hitting the database, and ctx only limits how
+// long this caller waits on it. The returned slice is shared, so callers
+// mustn't modify it.
+func (c *JobListCache) Get(ctx context.Context, sort string, db *sqlx.DB) ([]data.Job, error) {
+	sort = data.ValidSort(sort)
+	if c == nil {
+		return data.GetJobsSorted(ctx, sort, db)
+	}
+
+	c.mu.Lock()
+	if cached, ok := c.lists[sort]; ok && time.Now().Before(cached.expires) {
+		c.mu.Unlock()
+		return cached.jobs, nil
+	}
+
+	load, ok := c.loads[sort]
+	if !ok {
+		load = &jobListLoad{done: make(chan struct{})}
+		if c.loads == nil {
+			c.loads = make(map[string]*jobListLoad)
+		}
+		c.loads[sort] = load
+		go c.load(sort, load, c.generation, db)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-load.done:
+		return load.jobs, load.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load runs the query for a Get without holding the lock, so a slow query
+// only holds up requests for the same sort
+func (c *JobListCache) load(sort string, load *jobListLoad, generation int, db *sqlx.DB) {
+	defer close(load.done)
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	load.jobs, load.err = data.GetJobsSorted(ctx, sort, db)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loads[sort] == load {
+		delete(c.loads, sort)
+	}
+	if load.err != nil || generation != c.generation {
+		return
+	}
+
+	if c.lists == nil {
+		c.lists = make(map[string]cachedJobList)
+	}
+	c.lists[sort] = cachedJobList{jobs: load.jobs, expires: time.Now().Add(c.TTL)}
+}
+
+// Invalidate drops every cached list, so the next Get sees changes made
+// since
+func (c *JobListCache) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lists = nil
+	c.loads = nil
+	c.generation++
+}
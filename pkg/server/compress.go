@@ -0,0 +1,109 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compress gzips responses for clients that accept it. Whether a response
+// is compressed is decided when its body is first written, so images,
+// which are already compressed, and bodiless responses like 304s are sent
+// as they are.
+func compress(ctx *gin.Context) {
+	w := &gzipWriter{ResponseWriter: ctx.Writer, accepted: acceptsGzip(ctx.GetHeader("Accept-Encoding"))}
+	ctx.Writer = w
+
+	defer w.close()
+	ctx.Next()
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		// gzip;q=0 means anything but gzip
+		if _, q, ok := strings.Cut(params, "="); ok {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(q), 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressible reports whether a response of contentType is worth
+// compressing
+func compressible(contentType string) bool {
+	return contentType != "" && !strings.HasPrefix(contentType, "image/")
+}
+
+type gzipWriter struct {
+	gin.ResponseWriter
+	accepted bool
+
+	decided bool
+	gz      *gzip.Writer // nil unless the response is being compressed
+}
+
+// decide picks whether to compress the response, just before its headers
+// are sent
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	h := w.Header()
+	if w.Written() || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	if status := w.Status(); status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return
+	}
+
+	// Caches need to know the response depends on Accept-Encoding whether
+	// or not this client gets it compressed
+	h.Add("Vary", "Accept-Encoding")
+	if !w.accepted {
+		return
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cors lets pages on origins call the API from the browser, sending their
+// API key in the Authorization header and jobs as JSON. Preflight requests
+// are answered here, whether or not the origin is allowed, and origins
+// that aren't allowed just don't get the headers.
+func cors(origins []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if origin == "" {
+			return
+		}
+
+		ctx.Header("Vary", "Origin")
+		if allowedOrigin(origins, origin) {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+			ctx.Header("Access-Control-Allow-Methods", "GET, POST")
+			ctx.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			ctx.Header("Access-Control-Max-Age", "600")
+		}
+
+		if ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != "" {
+			ctx.AbortWithStatus(http.StatusNoContent)
+		}
+	}
+}
+
+// allowedOrigin reports whether origin is one of origins, or any origin
+// when that includes "*"
+func allowedOrigin(origins []string, origin string) bool {
+	for _, allowed := range origins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/services"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+func (ctrl *Controller) Subscribe(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("Subscribe failed to session.Save: %w", err))
+		}
+	}()
+
+	email, err := data.NormalizeEmail(ctx.PostForm("email"))
+	if err != nil {
+		flash(session, flashError, translate(ctx, err.Error()))
+		ctx.Redirect(302, "/")
+		return
+	}
+
+	sub, err := data.Subscribe(dbCtx, email, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to subscribe: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Say the same thing either way so this can't be used to find out who
+	// has subscribed
+	if !sub.Confirmed {
+		ctrl.notify(func() { ctrl.sendSubscribeConfirmation(sub) })
+	}
+
+	flash(session, flashInfo, translate(ctx, "flash.confirm_subscription"))
+	ctx.Redirect(302, "/")
+}
+
+func (ctrl *Controller) ConfirmSubscription(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	email := ctx.Query("email")
+	if !ValidEmailSignature("subscribe:"+email, ctx.Query("token"), ctrl.Config.AppSecrets()) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	if err := data.ConfirmSubscriber(dbCtx, email, ctrl.DB); err != nil {
+		log.Println(fmt.Errorf("failed to confirmSubscriber: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	session := sessions.Default(ctx)
+	flash(session, flashSuccess, translate(ctx, "flash.subscribed"))
+
+	// Redirects from a GET write a body, so the session has to be saved
+	// before redirecting rather than deferred
+	if err := session.Save(); err != nil {
+		log.Println(fmt.Errorf("ConfirmSubscription failed to session.Save: %w", err))
+	}
+
+	ctx.Redirect(302, "/")
+}
+
+func (ctrl *Controller) sendSubscribeConfirmation(sub data.Subscriber) {
+	if ctrl.EmailService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget)
+	defer cancel()
+
+	message := fmt.Sprintf(
+		"Thanks for subscribing to the weekly roundup of new jobs!\n\n<a href=\"%s\">Use this link to confirm your subscription</a>\n\nIf you didn't subscribe, you can ignore this email.",
+		html.EscapeString(SignedSubscribeConfirmRoute(sub.Email, ctrl.Config)),
+	)
+	err := services.Retry(ctx, notifyAttempts, notifyBaseDelay, func() error {
+		return ctrl.EmailService.SendEmail(sub.Email, "Confirm your subscription", message)
+	})
+	if err != nil {
+		log.Println(fmt.Errorf("failed to sendEmail: %w", err))
+		// continuing...
+	}
+}
+
+// SendDigests emails each subscriber who's due one the jobs posted since
+// their last digest. Subscribers are only marked as sent once their email
+// goes out, so anyone missed is retried the next time around.
+func SendDigests(ctx context.Context, db *sqlx.DB, emailService services.IEmailService, c *config.Config) error {
+	subs, err := data.GetSubscribersDueDigest(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to getSubscribersDueDigest: %w", err)
+	}
+
+	var failed int
+	for _, sub := range subs {
+		now := time.Now()
+
+		jobs, err := data.GetJobsPostedSince(ctx, sub.DigestSince(now), db)
+		if err != nil {
+			return fmt.Errorf("failed to getJobsPostedSince: %w", err)
+		}
+
+		// Quiet weeks are skipped rather than sending an empty email
+		if len(jobs) != 0 {
+			if err := emailService.SendEmail(sub.Email, "New jobs this week", DigestMessage(jobs, sub.Email, c)); err != nil {
+				log.Println(fmt.Errorf("failed to send digest: %w", err))
+				failed++
+				// continuing...
+				continue
+			}
+		}
+
+		if err := data.MarkDigestSent(ctx, sub.ID, now, db); err != nil {
+			return fmt.Errorf("failed to markDigestSent: %w", err)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed to send %d of %d digests", failed, len(subs))
+	}
+
+	return nil
+}
+
+// DigestMessage lists jobs for a digest email, with a link to unsubscribe
+func DigestMessage(jobs []data.Job, email string, c *config.Config) string {
+	var b strings.Builder
+
+	b.WriteString("Here are the latest jobs posted on the devICT Job Board:\n\n")
+	for _, job := range jobs {
+		fmt.Fprintf(
+			&b,
+			"<a href=\"%s%s\">%s @ %s</a>\n",
+			c.URL,
+			job.Path(),
+			html.EscapeString(job.Position),
+			html.EscapeString(job.Organization),
+		)
+	}
+	fmt.Fprintf(&b, "\n<a href=\"%s\">Unsubscribe</a>", html.EscapeString(SignedUnsubscribeRoute(email, c)))
+
+	return b.String()
+}
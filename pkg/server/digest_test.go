@@ -0,0 +1,141 @@
+package server_test
+
+import (
+	"context"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/server"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+var subscriberFields = []string{"id", "email", "confirmed", "created_at", "last_digest_at"}
+
+func TestSubscribe(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	dbmock.ExpectQuery(`INSERT INTO subscribers`).
+		WithArgs("test@example.com").
+		WillReturnRows(sqlmock.NewRows(subscriberFields).AddRow("1", "test@example.com", false, time.Now(), nil))
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	values := url.Values{"email": {"Test@Example.com"}}
+	body, resp := sendRequest(t, s.URL+"/subscribe", []byte(values.Encode()))
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Check your email for a link to confirm your subscription")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	if !assert.Len(t, svcmock.emails, 1) {
+		return
+	}
+	assert.Equal(t, "test@example.com", svcmock.emails[0].recipient)
+
+	link := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(svcmock.emails[0].body)
+	if !assert.Len(t, link, 2) {
+		return
+	}
+	confirmURL := html.UnescapeString(link[1])
+	assert.True(t, strings.HasPrefix(confirmURL, s.URL+"/subscribe/confirm?"))
+
+	// A tampered link doesn't confirm anything
+	_, resp = sendRequest(t, strings.Replace(confirmURL, "test%40example.com", "other%40example.com", 1), nil)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	dbmock.ExpectExec(`UPDATE subscribers SET confirmed = true`).
+		WithArgs("test@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	body, resp = sendRequest(t, confirmURL, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Regexp(t, `<p class="flash flash-success[^"]*">You&#39;re subscribed!`, body)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestSubscribeInvalidEmail(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	values := url.Values{"email": {"not an email"}}
+	body, resp := sendRequest(t, s.URL+"/subscribe", []byte(values.Encode()))
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Regexp(t, `<p class="flash flash-error[^"]*">`+regexp.QuoteMeta(en(data.ErrInvalidEmail))+`</p>`, body)
+	assert.Empty(t, svcmock.emails)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestSendDigests(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "postgres")
+
+	svcmock := &mockService{}
+	conf := &config.Config{AppSecret: "sup", URL: "https://jobs.devict.org"}
+
+	dbmock.ExpectQuery(`SELECT \* FROM subscribers WHERE confirmed`).
+		WillReturnRows(sqlmock.NewRows(subscriberFields).
+			AddRow("1", "busy@example.com", true, time.Now(), nil).
+			AddRow("2", "quiet@example.com", true, time.Now(), time.Now().AddDate(0, 0, -7)))
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at > \$1`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).
+			AddRow(mockJobRow(data.Job{ID: "1", Position: "Pos <1>", Organization: "Org"})...))
+	dbmock.ExpectExec(`UPDATE subscribers SET last_digest_at = \$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Nothing new, so no email, but it still counts as sent
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at > \$1`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+	dbmock.ExpectExec(`UPDATE subscribers SET last_digest_at = \$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), "2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, server.SendDigests(context.Background(), db, svcmock, conf))
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	if !assert.Len(t, svcmock.emails, 1) {
+		return
+	}
+	digest := svcmock.emails[0]
+	assert.Equal(t, "busy@example.com", digest.recipient)
+	assert.Contains(t, digest.body, `<a href="https://jobs.devict.org/jobs/1">Pos &lt;1&gt; @ Org</a>`)
+	assert.Contains(t, digest.body, html.EscapeString(server.SignedUnsubscribeRoute("busy@example.com", conf)))
+}
+
+func TestSendDigestsRetriesFailures(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "postgres")
+
+	svcmock := &mockService{emailFailures: 1}
+	conf := &config.Config{AppSecret: "sup", URL: "https://jobs.devict.org"}
+
+	dbmock.ExpectQuery(`SELECT \* FROM subscribers WHERE confirmed`).
+		WillReturnRows(sqlmock.NewRows(subscriberFields).AddRow("1", "test@example.com", true, time.Now(), nil))
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at > \$1`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{ID: "1"})...))
+
+	// Not marked as sent, so they'll get it next time
+	err = server.SendDigests(context.Background(), db, svcmock, conf)
+	assert.EqualError(t, err, "failed to send 1 of 1 digests")
+	assert.Empty(t, svcmock.emails)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
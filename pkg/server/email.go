@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"strings"
+)
+
+// EmailRenderer renders the html bodies of emails from the templates in the
+// email directory under the template path, named after their file without
+// the extension
+type EmailRenderer struct {
+	templates *template.Template
+}
+
+func NewEmailRenderer(templatePath string) (*EmailRenderer, error) {
+	templates, err := template.ParseGlob(path.Join(templatePath, "email", "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email templates: %w", err)
+	}
+
+	return &EmailRenderer{templates: templates}, nil
+}
+
+func (r *EmailRenderer) Render(name string, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := r.templates.ExecuteTemplate(&b, name+".html", data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/server"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestJobCreatedEmail(t *testing.T) {
+	emails, err := server.NewEmailRenderer("../../templates")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	conf := &config.Config{AppSecret: "sup", URL: "https://jobs.devict.org"}
+	job := data.Job{ID: "1", Position: "Pos <1>", Organization: "Org", Email: "test@example.com", PublishedAt: time.Now()}
+
+	body, err := emails.Render("job_created", map[string]interface{}{
+		"job":       job,
+		"editURL":   server.SignedJobRoute(job, conf),
+		"deleteURL": server.SignedDeleteRoute(job, conf),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Contains(t, body, "Pos &lt;1&gt;")
+
+	doc, err := html.Parse(strings.NewReader(body))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var links []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					links = append(links, attr.Val)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	assert.Equal(t, []string{server.SignedJobRoute(job, conf), server.SignedDeleteRoute(job, conf)}, links)
+}
+
+func TestEmailRendererMissingTemplate(t *testing.T) {
+	emails, err := server.NewEmailRenderer("../../templates")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = emails.Render("nope", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultEmbedLimit = 5
+	maxEmbedLimit     = 20
+)
+
+// embedScript replaces its own script tag (or fills the element named by
+// data-target) with an iframe of /embed/jobs. __URL__ is replaced with the
+// board's url when served.
+const embedScript = `(function () {
+  var script = document.currentScript;
+  if (!script) return;
+
+  var limit = parseInt(script.getAttribute("data-limit"), 10) || 0;
+  var frame = document.createElement("iframe");
+  frame.src = __URL__ + "/embed/jobs" + (limit > 0 ? "?limit=" + limit : "");
+  frame.title = "devICT Job Board";
+  frame.style.border = "0";
+  frame.style.width = "100%";
+  frame.style.height = script.getAttribute("data-height") || "400px";
+
+  var target = script.getAttribute("data-target");
+  var el = target && document.querySelector(target);
+  if (el) {
+    el.appendChild(frame);
+  } else {
+    script.parentNode.insertBefore(frame, script.nextSibling);
+  }
+})();
+`
+
+// setEmbedHeaders lets the embed be framed by the configured origins (any
+// origin when none are configured) while locking down everything else
+// about the page.
+func setEmbedHeaders(ctx *gin.Context, origins []string) {
+	ancestors := "*"
+	if len(origins) != 0 {
+		ancestors = strings.Join(origins, " ")
+	}
+
+	ctx.Header("Content-Security-Policy", fmt.Sprintf(
+		"default-src 'none'; style-src 'unsafe-inline'; frame-ancestors %s",
+		ancestors,
+	))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Referrer-Policy", "no-referrer")
+}
+
+func embedScriptFor(baseURL string) string {
+	// json encoding makes the url a safely quoted js string
+	quoted, _ := json.Marshal(baseURL)
+	return strings.Replace(embedScript, "__URL__", string(quoted), 1)
+}
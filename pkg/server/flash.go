@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/gob"
+
+	"github.com/gin-contrib/sessions"
+)
+
+// Flash levels, which pick how a flash message is styled
+const (
+	flashSuccess = "success"
+	flashError   = "error"
+	flashInfo    = "info"
+)
+
+// flashesKey is where the session keeps flashes added without a key
+const flashesKey = "_flash"
+
+// flashMessage is a message shown once at the top of the next page
+type flashMessage struct {
+	Level   string
+	Message string
+}
+
+func init() {
+	// Flashes are kept in the session, which is gob encoded
+	gob.Register(flashMessage{})
+}
+
+// flash adds a message at level to be shown on the next page rendered
+func flash(session sessions.Session, level, message string) {
+	session.AddFlash(flashMessage{Level: level, Message: message})
+}
+
+// flashMessages returns, and clears, the session's flashes. Plain strings
+// from sessions saved before flashes had levels are shown as info.
+func flashMessages(session sessions.Session) []flashMessage {
+	var messages []flashMessage
+	for _, f := range session.Flashes() {
+		switch f := f.(type) {
+		case flashMessage:
+			messages = append(messages, f)
+		case string:
+			messages = append(messages, flashMessage{Level: flashInfo, Message: f})
+		}
+	}
+	return messages
+}
@@ -1,8 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/i18n"
+	"github.com/yuin/goldmark"
 )
 
 func formatAsDate(t time.Time) string {
@@ -10,6 +21,84 @@ func formatAsDate(t time.Time) string {
 	return fmt.Sprintf("%d/%02d/%02d", year, month, day)
 }
 
+// timeAgo is how long ago t was in the visitor's language, e.g. "5 days
+// ago"
+func timeAgo(locale string, t time.Time) string {
+	return i18n.TimeAgo(locale, t, time.Now())
+}
+
 func formatAsRfc3339String(t time.Time) string {
 	return t.Format(time.RFC3339)
 }
+
+var (
+	htmlTagPattern          = regexp.MustCompile(`<[^>]*>`)
+	spaceBeforePunctPattern = regexp.MustCompile(`\s+([.,;:!?)])`)
+)
+
+// markdownToPlaintext renders markdown and strips the resulting markup,
+// leaving whitespace-collapsed text suitable for meta tags.
+func markdownToPlaintext(markdown string) string {
+	var b bytes.Buffer
+	if err := goldmark.Convert([]byte(markdown), &b); err != nil {
+		b.Reset()
+		b.WriteString(html.EscapeString(markdown))
+	}
+
+	// Tags become spaces so block elements don't run together, which
+	// leaves stray spaces in front of punctuation after inline elements.
+	text := html.UnescapeString(htmlTagPattern.ReplaceAllString(b.String(), " "))
+	text = strings.Join(strings.Fields(text), " ")
+	return spaceBeforePunctPattern.ReplaceAllString(text, "$1")
+}
+
+// truncate shortens s to at most n runes, cutting at a word boundary where
+// possible and marking the cut with an ellipsis.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	cut := string(runes[:n-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+// humanize turns a key like "visa_sponsorship" into "Visa sponsorship"
+func humanize(key string) string {
+	s := strings.TrimSpace(strings.NewReplacer("_", " ", "-", " ").Replace(key))
+	if s == "" {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// initial is the first letter of s, shown in place of a missing logo
+func initial(s string) string {
+	for _, r := range strings.TrimSpace(s) {
+		return strings.ToUpper(string(r))
+	}
+	return ""
+}
+
+// orgPath is the path of an organization's page. Names are escaped whole,
+// slashes included, so they round trip through the /orgs/*name route.
+func orgPath(organization string) string {
+	return "/orgs/" + url.PathEscape(organization)
+}
+
+// localizedJob lets the shared job template translate its labels, since a
+// template only has access to what it's passed
+type localizedJob struct {
+	data.Job
+	Locale string
+}
+
+func localized(locale string, job data.Job) localizedJob {
+	return localizedJob{Job: job, Locale: locale}
+}
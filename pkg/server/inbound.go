@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devict/job-board/pkg/data"
+)
+
+// inboundEmailMaxAge bounds how old a signed inbound email webhook can be
+// before we treat it as a replay.
+const inboundEmailMaxAge = 15 * time.Minute
+
+// InboundEmail is the form payload posted by the email provider's inbound
+// routing webhook (Mailgun's format).
+type InboundEmail struct {
+	Sender    string `form:"sender"`
+	From      string `form:"from"`
+	Subject   string `form:"subject"`
+	BodyPlain string `form:"body-plain"`
+	Timestamp string `form:"timestamp"`
+	Token     string `form:"token"`
+	Signature string `form:"signature"`
+}
+
+func SignatureForInboundEmail(timestamp, token, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (email *InboundEmail) Verify(key string, now time.Time) error {
+	ts, err := strconv.ParseInt(email.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %w", email.Timestamp, err)
+	}
+
+	if age := now.Sub(time.Unix(ts, 0)); age > inboundEmailMaxAge || age < -inboundEmailMaxAge {
+		return fmt.Errorf("timestamp %q is outside the allowed window", email.Timestamp)
+	}
+
+	expected := SignatureForInboundEmail(email.Timestamp, email.Token, key)
+	if !hmac.Equal([]byte(expected), []byte(email.Signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
+
+// NewJob maps the email onto a job posting. The subject is used as the
+// position, and may name the organization as "Position @ Organization";
+// otherwise the sender's domain stands in for the organization.
+func (email *InboundEmail) NewJob() data.NewJob {
+	sender := email.Sender
+	if addr, err := mail.ParseAddress(email.From); err == nil && sender == "" {
+		sender = addr.Address
+	}
+
+	position := strings.TrimSpace(email.Subject)
+	organization := ""
+	if i := strings.LastIndex(position, " @ "); i != -1 {
+		organization = strings.TrimSpace(position[i+3:])
+		position = strings.TrimSpace(position[:i])
+	} else if i := strings.LastIndex(sender, "@"); i != -1 {
+		organization = sender[i+1:]
+	}
+
+	return data.NewJob{
+		Position:     position,
+		Organization: organization,
+		Description:  strings.TrimSpace(email.BodyPlain),
+		Email:        sender,
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/devict/job-board/pkg/data"
+)
+
+// jobPosting is the subset of https://schema.org/JobPosting we can fill in
+// from a job.
+type jobPosting struct {
+	Context            string          `json:"@context"`
+	Type               string          `json:"@type"`
+	Title              string          `json:"title"`
+	Description        string          `json:"description,omitempty"`
+	DatePosted         string          `json:"datePosted"`
+	ValidThrough       string          `json:"validThrough"`
+	URL                string          `json:"url"`
+	HiringOrganization postingEmployer `json:"hiringOrganization"`
+}
+
+type postingEmployer struct {
+	Type   string `json:"@type"`
+	Name   string `json:"name"`
+	SameAs string `json:"sameAs,omitempty"`
+	Logo   string `json:"logo,omitempty"`
+}
+
+// jobPostingJSONLD renders job as schema.org JSON-LD for search engines.
+// json.Marshal escapes <, >, and & so the output is safe inside a <script>.
+func jobPostingJSONLD(job data.Job, description string, baseURL string) (template.JS, error) {
+	posting := jobPosting{
+		Context:      "https://schema.org",
+		Type:         "JobPosting",
+		Title:        job.Position,
+		Description:  description,
+		DatePosted:   job.PublishedAt.Format("2006-01-02"),
+		ValidThrough: job.ExpiresAt().Format(time.RFC3339),
+		URL:          baseURL + job.Path(),
+		HiringOrganization: postingEmployer{
+			Type: "Organization",
+			Name: job.Organization,
+		},
+	}
+
+	if job.Url.Valid {
+		posting.HiringOrganization.SameAs = job.Url.String
+	}
+
+	if job.LogoUrl.Valid {
+		posting.HiringOrganization.Logo = job.LogoUrl.String
+		if strings.HasPrefix(job.LogoUrl.String, "/") {
+			posting.HiringOrganization.Logo = baseURL + job.LogoUrl.String
+		}
+	}
+
+	b, err := json.Marshal(posting)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job posting (job id: %s): %w", job.ID, err)
+	}
+
+	return template.JS(b), nil
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-gonic/gin"
+)
+
+const maxLogoSize = 1 << 20 // 1MB
+
+type logoUpload struct {
+	name        string
+	contentType string
+	content     []byte
+}
+
+// readLogo loads the optional "logo" file from a multipart form. It returns
+// nil when no logo was uploaded, or a validation message when the upload is
+// too large or isn't a png, jpg, or svg.
+func readLogo(ctx *gin.Context) (*logoUpload, string) {
+	header, err := ctx.FormFile("logo")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return nil, ""
+	} else if err != nil {
+		log.Println(fmt.Errorf("readLogo failed to ctx.FormFile: %w", err))
+		return nil, data.ErrInvalidLogo
+	}
+
+	if header.Size > maxLogoSize {
+		return nil, data.ErrLogoTooLarge
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		log.Println(fmt.Errorf("readLogo failed to open upload: %w", err))
+		return nil, data.ErrInvalidLogo
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxLogoSize+1))
+	if err != nil {
+		log.Println(fmt.Errorf("readLogo failed to read upload: %w", err))
+		return nil, data.ErrInvalidLogo
+	}
+
+	if len(content) > maxLogoSize {
+		return nil, data.ErrLogoTooLarge
+	}
+
+	ext := logoExtension(header.Filename, content)
+	if ext == "" {
+		return nil, data.ErrInvalidLogo
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Println(fmt.Errorf("readLogo failed to generate a name: %w", err))
+		return nil, data.ErrInvalidLogo
+	}
+
+	return &logoUpload{name: hex.EncodeToString(id) + ext, contentType: mime.TypeByExtension(ext), content: content}, ""
+}
+
+// logoExtension sniffs the file content to decide what kind of image it is,
+// returning "" for anything that isn't an allowed type.
+func logoExtension(filename string, content []byte) string {
+	switch contentType := http.DetectContentType(content); {
+	case contentType == "image/png":
+		return ".png"
+	case contentType == "image/jpeg":
+		return ".jpg"
+	case strings.EqualFold(filepath.Ext(filename), ".svg") &&
+		(strings.HasPrefix(contentType, "text/xml") || strings.HasPrefix(contentType, "text/plain")) &&
+		bytes.Contains(content, []byte("<svg")):
+		return ".svg"
+	}
+
+	return ""
+}
+
+// serveUploads sets headers that stop uploaded files (svgs in particular)
+// from running scripts when opened directly.
+func serveUploads(ctx *gin.Context) {
+	ctx.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/i18n"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// shedLoad limits how many requests the wrapped routes serve at once,
+// turning away the rest with a 503 rather than letting them queue up on
+// database connections. A max of zero or less disables the limit.
+func shedLoad(max int) gin.HandlerFunc {
+	if max <= 0 {
+		return func(ctx *gin.Context) {}
+	}
+
+	sem := make(chan struct{}, max)
+	return func(ctx *gin.Context) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			ctx.Next()
+		default:
+			ctx.Header("Retry-After", "5")
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// localeKey is where localize keeps the visitor's locale in the context
+const localeKey = "locale"
+
+// localize picks which language to show each visitor from their browser's
+// Accept-Language header
+func localize(ctx *gin.Context) {
+	ctx.Set(localeKey, i18n.FromAcceptLanguage(ctx.GetHeader("Accept-Language")))
+}
+
+// translate returns the text for key in the visitor's language
+func translate(ctx *gin.Context, key string, args ...interface{}) string {
+	return i18n.Translate(ctx.GetString(localeKey), key, args...)
+}
+
+// adminAuth is basic auth against the single ADMIN_USER/ADMIN_PASSWORD pair
+// and the bcrypt hashed ADMIN_ACCOUNTS. Browsers resend basic auth on
+// cross-site form posts, so anything but a GET or HEAD also has to come
+// from one of the board's own pages.
+func adminAuth(c *config.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, password, ok := ctx.Request.BasicAuth()
+		if !ok || !validAdmin(c, user, password) {
+			ctx.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		method := ctx.Request.Method
+		if method != http.MethodGet && method != http.MethodHead && !sameOrigin(ctx.Request, c.URL) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Set(gin.AuthUserKey, user)
+	}
+}
+
+// sameOrigin reports whether r was sent from a page on the board, going by
+// its Origin header, or its Referer when there's no Origin. Requests with
+// neither are turned away, since there's no telling where they came from.
+// The host is compared rather than the whole origin, so the board works
+// behind a proxy that terminates TLS.
+func sameOrigin(r *http.Request, appURL string) bool {
+	source := r.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = r.Referer()
+	}
+	if source == "" {
+		return false
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if app, err := url.Parse(appURL); err == nil && app.Host != "" {
+		return strings.EqualFold(u.Host, app.Host)
+	}
+	return false
+}
+
+// apiAuth checks the request's "Authorization: Bearer <key>" header
+// against keys
+func apiAuth(keys []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !validAPIKey(keys, ctx.GetHeader("Authorization")) {
+			ctx.Header("WWW-Authenticate", `Bearer realm="api"`)
+			apiError(ctx, http.StatusUnauthorized, "unauthorized", "a valid API key is required")
+			return
+		}
+	}
+}
+
+func validAPIKey(keys []string, header string) bool {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	token := []byte(strings.TrimSpace(header[len(prefix):]))
+
+	valid := false
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		// Every key is compared so which one matched doesn't change how
+		// long this takes
+		if subtle.ConstantTimeCompare(token, []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func validAdmin(c *config.Config, user, password string) bool {
+	if hash, ok := c.AdminAccounts[user]; ok {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+
+	if c.AdminUser == "" || c.AdminPassword == "" {
+		return false
+	}
+
+	// Both are compared so a wrong username takes as long as a wrong password
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(c.AdminUser)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(c.AdminPassword)) == 1
+	return userOK && passwordOK
+}
+
+// errorPages renders the error page in place of the empty responses
+// handlers abort with on server errors, and for panics, which are logged
+// with their stack trace. API requests get the API's error envelope
+// instead. The error itself is never shown to the visitor. Responses that
+// already have a body are left alone.
+func errorPages(ctx *gin.Context) {
+	ctx.Writer = &errorPageWriter{ctx.Writer}
+
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("request %s recovered from panic: %v\n%s", ctx.GetString(requestIDKey), err, debug.Stack())
+			ctx.Abort()
+			ctx.Status(http.StatusInternalServerError)
+		}
+
+		if ctx.Writer.Status() != http.StatusInternalServerError || ctx.Writer.Written() {
+			return
+		}
+
+		if isAPIRequest(ctx) {
+			apiError(ctx, http.StatusInternalServerError, "internal_error", "something went wrong")
+			return
+		}
+		ctx.HTML(http.StatusInternalServerError, "error", addFlash(ctx, gin.H{
+			"requestID": ctx.GetString(requestIDKey),
+		}))
+	}()
+
+	ctx.Next()
+}
+
+// errorPageWriter holds back the headers of server errors until there's a
+// body, so errorPages still has the chance to render one
+type errorPageWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *errorPageWriter) WriteHeaderNow() {
+	if w.Status() == http.StatusInternalServerError && !w.Written() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// requestIDKey is where requestID keeps the request's ID in the context
+const requestIDKey = "requestID"
+
+// requestIDHeader carries the ID of each request, so what a visitor reports
+// can be matched up with the logs
+const requestIDHeader = "X-Request-ID"
+
+// requestID gives each request an ID, reusing the one a proxy in front of
+// us already set when it's reasonable, and sends it back in the response
+func requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+
+	ctx.Set(requestIDKey, id)
+	ctx.Header(requestIDHeader, id)
+}
+
+// validRequestID keeps incoming IDs short and free of anything that could
+// mess with the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID makes a random (version 4) UUID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(fmt.Errorf("newRequestID failed to read random bytes: %w", err))
+		// continuing...
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// logRequest is gin's request log line with the request's ID on the end
+func logRequest(param gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | %s\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		param.Path,
+		param.Keys[requestIDKey],
+		param.ErrorMessage,
+	)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"log"
+	"sync"
+)
+
+// NotificationQueue sends notifications from a background worker so that
+// handlers don't wait on email, Slack, etc.
+type NotificationQueue struct {
+	queue   chan func()
+	pending sync.WaitGroup
+	stopped chan struct{}
+}
+
+// NewNotificationQueue starts a worker that runs queued notifications in
+// order. size is how many can be waiting before Enqueue falls back to
+// running them inline.
+func NewNotificationQueue(size int) *NotificationQueue {
+	q := &NotificationQueue{
+		queue:   make(chan func(), size),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(q.stopped)
+		for fn := range q.queue {
+			fn()
+			q.pending.Done()
+		}
+	}()
+
+	return q
+}
+
+func (q *NotificationQueue) Enqueue(fn func()) {
+	q.pending.Add(1)
+	select {
+	case q.queue <- fn:
+	default:
+		log.Println("notification queue is full, sending inline")
+		fn()
+		q.pending.Done()
+	}
+}
+
+// Flush waits for everything queued so far to be sent
+func (q *NotificationQueue) Flush() {
+	q.pending.Wait()
+}
+
+// Close stops the queue once everything queued has been sent. Nothing may
+// be enqueued after calling it.
+func (q *NotificationQueue) Close() {
+	close(q.queue)
+	<-q.stopped
+}
+
+// notify sends fn through the queue, or right away when there isn't one
+func (ctrl *Controller) notify(fn func()) {
+	if ctrl.Notifications == nil {
+		fn()
+		return
+	}
+	ctrl.Notifications.Enqueue(fn)
+}
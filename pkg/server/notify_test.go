@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devict/job-board/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationQueueDrainsOnClose(t *testing.T) {
+	q := server.NewNotificationQueue(10)
+
+	var sent []int
+	for i := 0; i < 5; i++ {
+		i := i
+		q.Enqueue(func() {
+			time.Sleep(time.Millisecond)
+			sent = append(sent, i)
+		})
+	}
+
+	q.Close()
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, sent)
+}
+
+func TestNotificationQueueFull(t *testing.T) {
+	q := server.NewNotificationQueue(1)
+	defer q.Close()
+
+	// Hold up the worker and fill the one queue slot
+	started, release := make(chan struct{}), make(chan struct{})
+	q.Enqueue(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	q.Enqueue(func() {})
+
+	// With no room to queue, it's sent before Enqueue returns
+	sent := false
+	q.Enqueue(func() { sent = true })
+	assert.True(t, sent)
+
+	close(release)
+}
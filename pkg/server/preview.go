@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// previewsPerMinute is generous, since the forms only ask for a
+	// preview when the poster clicks the button
+	previewsPerMinute = 30
+	maxPreviewSize    = 64 << 10 // 64KB
+)
+
+// PreviewDescription renders the posted description the same way the job
+// page will, returning the HTML fragment for the forms to show.
+func (ctrl *Controller) PreviewDescription(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxPreviewSize)
+
+	description, ok := ctx.GetPostForm("description")
+	if !ok {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	html, err := data.RenderMarkdown(description)
+	if err != nil {
+		log.Println(fmt.Errorf("PreviewDescription failed to renderMarkdown: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimiter is a per-key token bucket: each key may make up to perMinute
+// requests in a burst, refilling at perMinute tokens per minute.
+type rateLimiter struct {
+	perMinute float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: float64(perMinute),
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// allow takes a token from key's bucket, returning how long until one is
+// available if the bucket is empty.
+func (rl *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.prune(now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rl.perMinute, last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = math.Min(rl.perMinute, b.tokens+now.Sub(b.last).Minutes()*rl.perMinute)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rl.perMinute * float64(time.Minute))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have had time to refill completely, since they
+// are equivalent to a fresh bucket. Callers must hold rl.mu.
+func (rl *rateLimiter) prune(now time.Time) {
+	if now.Sub(rl.lastPrune) < time.Minute {
+		return
+	}
+
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastPrune = now
+}
+
+// rateLimit stops clients making more than perMinute requests a minute to
+// the wrapped routes, responding with onLimited, or a bare 429 when it's
+// nil. Either way a Retry-After header is set. A perMinute of zero or less
+// disables it.
+func rateLimit(perMinute int, onLimited func(ctx *gin.Context, wait time.Duration)) gin.HandlerFunc {
+	if perMinute <= 0 {
+		return func(ctx *gin.Context) {}
+	}
+
+	rl := newRateLimiter(perMinute)
+	return func(ctx *gin.Context) {
+		ok, wait := rl.allow(ctx.ClientIP(), time.Now())
+		if ok {
+			return
+		}
+
+		ctx.Header("Retry-After", fmt.Sprint(retryAfterSeconds(wait)))
+		if onLimited == nil {
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+
+		onLimited(ctx, wait)
+		ctx.Abort()
+	}
+}
+
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/services"
+	"github.com/jmoiron/sqlx"
+)
+
+// SendExpiryReminders emails the posters of jobs expiring within
+// ExpiryReminderLeadTime. Jobs are only marked as reminded once the email
+// goes out, so anyone missed is retried the next time around.
+func SendExpiryReminders(ctx context.Context, db *sqlx.DB, emailService services.IEmailService, c *config.Config) error {
+	jobs, err := data.GetJobsDueReminder(ctx, time.Now(), c.ExpiryReminderLeadTime, db)
+	if err != nil {
+		return fmt.Errorf("failed to getJobsDueReminder: %w", err)
+	}
+
+	var failed int
+	for _, job := range jobs {
+		if err := emailService.SendEmail(job.Email, "Your job posting expires soon", ExpiryReminderMessage(job, c)); err != nil {
+			log.Println(fmt.Errorf("failed to send expiry reminder: %w", err))
+			failed++
+			// continuing...
+			continue
+		}
+
+		if err := data.MarkReminderSent(ctx, job.ID, db); err != nil {
+			return fmt.Errorf("failed to markReminderSent: %w", err)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("failed to send %d of %d expiry reminders", failed, len(jobs))
+	}
+
+	return nil
+}
+
+// ExpiryReminderMessage tells a poster when their job will be removed, with
+// the link to edit it
+func ExpiryReminderMessage(job data.Job, c *config.Config) string {
+	return fmt.Sprintf(
+		"Your posting for %s @ %s will be removed from the devICT Job Board on %s.\n\n<a href=\"%s\">Use this link to edit or remove it before then</a>\n\nIf the position is still open afterwards, you're welcome to post it again.",
+		html.EscapeString(job.Position),
+		html.EscapeString(job.Organization),
+		job.ExpiresAt().Format("January 2"),
+		html.EscapeString(SignedJobRoute(job, c)),
+	)
+}
@@ -0,0 +1,61 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/server"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendExpiryReminders(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "postgres")
+
+	svcmock := &mockService{}
+	conf := &config.Config{AppSecret: "sup", URL: "https://jobs.devict.org", ExpiryReminderLeadTime: 72 * time.Hour}
+
+	job := data.Job{ID: "1", Position: "Pos <1>", Organization: "Org"}
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at < \$1 AND NOT reminder_sent`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(job)...))
+	dbmock.ExpectExec(`UPDATE jobs SET reminder_sent = true WHERE id = \$1`).
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, server.SendExpiryReminders(context.Background(), db, svcmock, conf))
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	if !assert.Len(t, svcmock.emails, 1) {
+		return
+	}
+	reminder := svcmock.emails[0]
+	assert.Equal(t, "example@example.com", reminder.recipient)
+	assert.Contains(t, reminder.body, "Pos &lt;1&gt; @ Org")
+	assert.Contains(t, reminder.body, "https://jobs.devict.org/jobs/1/edit?token=")
+}
+
+func TestSendExpiryRemindersRetriesFailures(t *testing.T) {
+	mockDB, dbmock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "postgres")
+
+	svcmock := &mockService{emailFailures: 1}
+	conf := &config.Config{AppSecret: "sup", URL: "https://jobs.devict.org", ExpiryReminderLeadTime: 72 * time.Hour}
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE published_at < \$1 AND NOT reminder_sent`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{ID: "1"})...))
+
+	// Not marked as reminded, so they'll get it next time
+	err = server.SendExpiryReminders(context.Background(), db, svcmock, conf)
+	assert.EqualError(t, err, "failed to send 1 of 1 expiry reminders")
+	assert.Empty(t, svcmock.emails)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
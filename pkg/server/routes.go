@@ -1,10 +1,17 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
@@ -15,65 +22,246 @@ import (
 )
 
 type Controller struct {
-	DB             *sqlx.DB
-	EmailService   services.IEmailService
-	SlackService   services.ISlackService
-	TwitterService services.ITwitterService
-	Config         *config.Config
+	DB              *sqlx.DB
+	EmailService    services.IEmailService
+	SlackService    services.ISlackService
+	DiscordService  services.IDiscordService
+	MastodonService services.IMastodonService
+	WebhookService  services.IWebhookService
+	Notifications   *NotificationQueue
+	TwitterService  services.ITwitterService
+	CaptchaService  services.ICaptchaService
+	FileStore       services.IFileStore
+	Emails          *EmailRenderer
+	Config          *config.Config
+
+	// JobList caches the published jobs shown on the index. Nil disables.
+	JobList *JobListCache
+
+	// Email domains jobs can't be posted from
+	BlockedDomains data.BlockedDomains
 }
 
+// sortKey holds the last sort picked on the index in the session, so it's
+// kept when coming back without ?sort=. Index also leaves it in the context
+// for setCacheHeaders, since it changes the page.
+const sortKey = "sort"
+
 func (ctrl *Controller) Index(ctx *gin.Context) {
-	jobs, err := data.GetAllJobs(ctrl.DB)
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	session := sessions.Default(ctx)
+	var sort string
+	if picked, ok := ctx.GetQuery("sort"); ok {
+		sort = data.ValidSort(picked)
+		if session.Get(sortKey) != sort {
+			session.Set(sortKey, sort)
+			if err := session.Save(); err != nil {
+				log.Println(fmt.Errorf("Index failed to session.Save: %w", err))
+			}
+		}
+	} else {
+		stored, _ := session.Get(sortKey).(string)
+		sort = data.ValidSort(stored)
+	}
+	ctx.Set(sortKey, sort)
+
+	jobs, err := ctrl.JobList.Get(dbCtx, sort, ctrl.DB)
 	if err != nil {
-		log.Println(fmt.Errorf("Index failed to getAllJobs: %w", err))
+		log.Println(fmt.Errorf("Index failed to getJobsSorted: %w", err))
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	ctx.HTML(200, "index", addFlash(ctx, gin.H{
-		"jobs":   jobs,
-		"noJobs": len(jobs) == 0,
+	if setCacheHeaders(ctx, jobs...) {
+		return
+	}
+	if isHead(ctx) {
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	var lastUpdated time.Time
+	for _, job := range jobs {
+		if job.PublishedAt.After(lastUpdated) {
+			lastUpdated = job.PublishedAt
+		}
+	}
+
+	tVars := gin.H{
+		"jobs":          jobs,
+		"noJobs":        len(jobs) == 0,
+		"jobCount":      len(jobs),
+		"lastUpdated":   lastUpdated,
+		"sort":          sort,
+		"subscriptions": ctrl.EmailService != nil,
+	}
+
+	if ctrl.Config.GroupByOrg {
+		tVars["groups"] = data.GroupByOrganization(jobs)
+	}
+
+	ctx.HTML(200, "index", addFlash(ctx, tVars))
+}
+
+func (ctrl *Controller) ViewOrganization(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	organization := strings.TrimPrefix(ctx.Param("name"), "/")
+	jobs, err := data.GetJobsByOrganization(dbCtx, organization, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("ViewOrganization failed to getJobsByOrganization: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if len(jobs) == 0 {
+		status = http.StatusNotFound
+	}
+
+	// Only pages that were found are worth revalidating
+	if status == http.StatusOK && setCacheHeaders(ctx, jobs...) {
+		return
+	}
+	ctx.HTML(status, "index", addFlash(ctx, gin.H{
+		"jobs":         jobs,
+		"noJobs":       len(jobs) == 0,
+		"organization": organization,
 	}))
 }
 
-func (ctrl *Controller) NewJob(ctx *gin.Context) {
-	session := sessions.Default(ctx)
+func (ctrl *Controller) EmbedJobs(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
 
-	fields := []string{"position", "organization", "url", "description", "email"}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(defaultEmbedLimit)))
+	if err != nil || limit < 1 {
+		limit = defaultEmbedLimit
+	} else if limit > maxEmbedLimit {
+		limit = maxEmbedLimit
+	}
+
+	jobs, err := ctrl.JobList.Get(dbCtx, data.SortNewest, ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("EmbedJobs failed to getAllJobs: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
-	tVars := gin.H{}
-	for _, k := range fields {
-		f := fmt.Sprintf("%s_err", k)
-		tVars[f] = session.Flashes(f)
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
 	}
 
-	ctx.HTML(200, "new", addFlash(ctx, tVars))
+	setEmbedHeaders(ctx, ctrl.Config.EmbedOrigins)
+	ctx.HTML(200, "embed", gin.H{"jobs": jobs, "url": ctrl.Config.URL})
+}
+
+func (ctrl *Controller) EmbedScript(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=3600")
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Data(200, "application/javascript; charset=utf-8", []byte(embedScriptFor(ctrl.Config.URL)))
+}
+
+func (ctrl *Controller) Health(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	status := http.StatusOK
+	dbStatus := "ok"
+	if err := ctrl.DB.PingContext(dbCtx); err != nil {
+		log.Println(fmt.Errorf("Health failed to ping db: %w", err))
+		status = http.StatusServiceUnavailable
+		dbStatus = err.Error()
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+
+	token := ctx.GetHeader("X-Health-Token")
+	if token == "" {
+		token = ctx.Query("token")
+	}
+
+	expected := ctrl.Config.HealthCheckToken
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		ctx.Status(status)
+		return
+	}
+
+	ctx.JSON(status, gin.H{"database": dbStatus})
+}
+
+func (ctrl *Controller) NewJob(ctx *gin.Context) {
+	ctrl.renderNewJob(ctx, http.StatusOK, nil, map[string]string{})
+}
+
+// renderNewJob renders the job form. After a failed submission, errs are
+// shown next to their fields and values fills the form back in. The form
+// is rendered in response to the submission, rather than redirected to,
+// since a long description won't fit in the session cookie.
+func (ctrl *Controller) renderNewJob(ctx *gin.Context, status int, errs map[string]string, values map[string]string) {
+	tVars := gin.H{
+		"logoUploads":  ctrl.FileStore != nil,
+		"metadataKeys": ctrl.Config.AllowedMetadataKeys,
+		"limits":       ctrl.limits(),
+		"values":       values,
+	}
+	if ctrl.CaptchaService != nil {
+		tVars["captchaProvider"] = ctrl.Config.CaptchaProvider
+		tVars["captchaSiteKey"] = ctrl.Config.CaptchaSiteKey
+	}
+	addFieldErrors(ctx, tVars, errs)
+
+	ctx.HTML(status, "new", addFlash(ctx, tVars))
 }
 
 func (ctrl *Controller) EditJob(ctx *gin.Context) {
-	session := sessions.Default(ctx)
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
 
 	id := ctx.Param("id")
-	job, err := data.GetJob(id, ctrl.DB)
-	if err != nil {
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
 		log.Println(fmt.Errorf("failed to getJob: %w", err))
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	token := ctx.Query("token")
-	tVars := gin.H{"job": job, "token": token}
+	ctrl.renderEditJob(ctx, http.StatusOK, job, nil)
+}
 
-	fields := []string{"position", "organization", "url", "description", "email"}
-	for _, k := range fields {
-		f := fmt.Sprintf("%s_err", k)
-		tVars[f] = session.Flashes(f)
+// renderEditJob renders the edit form for job, with errs shown next to
+// their fields after a failed update
+func (ctrl *Controller) renderEditJob(ctx *gin.Context, status int, job data.Job, errs map[string]string) {
+	tVars := gin.H{
+		"job":          job,
+		"token":        ctx.Query("token"),
+		"metadataKeys": ctrl.Config.AllowedMetadataKeys,
+		"logoUploads":  ctrl.FileStore != nil,
+		"limits":       ctrl.limits(),
 	}
+	addFieldErrors(ctx, tVars, errs)
 
-	ctx.HTML(200, "edit", addFlash(ctx, tVars))
+	ctx.HTML(status, "edit", addFlash(ctx, tVars))
+}
+
+// addFieldErrors translates errs, keyed by field, into where the job forms
+// show each field's errors
+func addFieldErrors(ctx *gin.Context, tVars gin.H, errs map[string]string) {
+	for k, v := range errs {
+		tVars[fmt.Sprintf("%s_err", k)] = []string{translate(ctx, v)}
+	}
 }
 
 func (ctrl *Controller) CreateJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
 	var newJobInput data.NewJob
 	if err := ctx.Bind(&newJobInput); err != nil {
 		log.Println(fmt.Errorf("failed to ctx.Bind: %w", err))
@@ -88,55 +276,164 @@ func (ctrl *Controller) CreateJob(ctx *gin.Context) {
 		}
 	}()
 
-	if errs := newJobInput.Validate(false); len(errs) != 0 {
-		for k, v := range errs {
-			session.AddFlash(v, fmt.Sprintf("%s_err", k))
+	newJobInput.Metadata = metadataFromForm(ctx)
+
+	errs := newJobInput.Validate(false, ctrl.Config.AllowedMetadataKeys, ctrl.limits(), ctrl.BlockedDomains)
+
+	if ctrl.CaptchaService != nil {
+		// hCaptcha also fills in g-recaptcha-response for compatibility
+		token := ctx.PostForm("g-recaptcha-response")
+		if err := ctrl.CaptchaService.VerifyCaptcha(token, ctx.ClientIP()); err != nil {
+			log.Println(fmt.Errorf("CreateJob failed to verifyCaptcha: %w", err))
+			errs["captcha"] = data.ErrCaptchaFailed
 		}
+	}
 
-		ctx.Redirect(302, "/new")
-		return
+	var logo *logoUpload
+	if ctrl.FileStore != nil {
+		var logoErr string
+		if logo, logoErr = readLogo(ctx); logoErr != "" {
+			errs["logo"] = logoErr
+		}
 	}
 
-	job, err := newJobInput.SaveToDB(ctrl.DB)
-	if err != nil {
-		log.Println(fmt.Errorf("failed to save job to db: %w", err))
-		session.AddFlash("Error creating job")
-		ctx.Redirect(302, "/new")
+	if len(errs) != 0 {
+		ctrl.renderNewJob(ctx, http.StatusUnprocessableEntity, errs, submittedValues(ctx, ctrl.Config.AllowedMetadataKeys))
 		return
 	}
 
-	if ctrl.EmailService != nil {
-		// TODO: make this a nicer html template?
-		message := fmt.Sprintf(
-			"Your job has been created!\n\n<a href=\"%s\">Use this link to edit the job posting</a>",
-			SignedJobRoute(job, ctrl.Config),
-		)
-		err = ctrl.EmailService.SendEmail(newJobInput.Email, "Job Created!", message)
+	if ctrl.Config.DuplicateWindow > 0 {
+		existing, err := data.FindRecentDuplicate(dbCtx, newJobInput, ctrl.Config.DuplicateWindow, ctrl.DB)
 		if err != nil {
-			log.Println(fmt.Errorf("failed to sendEmail: %w", err))
+			log.Println(fmt.Errorf("failed to check for duplicate job: %w", err))
 			// continuing...
+		} else if existing.ID != "" {
+			flash(session, flashInfo, translate(ctx, "flash.duplicate_job"))
+			if existing.IsPublished() {
+				ctx.Redirect(302, existing.Path())
+			} else {
+				ctx.Redirect(302, "/")
+			}
+			return
 		}
 	}
 
-	if ctrl.SlackService != nil {
-		if err = ctrl.SlackService.PostToSlack(job); err != nil {
-			log.Println(fmt.Errorf("failed to postToSlack: %w", err))
-			// continuing...
+	if logo != nil {
+		logoUrl, err := ctrl.FileStore.Save(logo.name, bytes.NewReader(logo.content), logo.contentType)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to store logo: %w", err))
+			flash(session, flashError, translate(ctx, "flash.create_failed"))
+			ctx.Redirect(302, "/new")
+			return
 		}
+		newJobInput.LogoUrl = logoUrl
 	}
 
-	if ctrl.TwitterService != nil {
-		if err = ctrl.TwitterService.PostToTwitter(job); err != nil {
-			log.Println(fmt.Errorf("failed to postToTwitter: %w", err))
-			// continuing...
-		}
+	newJobInput.Confirmed = !ctrl.Config.RequireConfirmation
+	if ctrl.Config.RequireApproval {
+		newJobInput.Status = data.StatusPending
 	}
 
-	session.AddFlash("Job created!")
+	job, err := newJobInput.SaveToDB(dbCtx, ctrl.DB, ctrl.Config.DuplicateSimilarityThreshold)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to save job to db: %w", err))
+		flash(session, flashError, translate(ctx, "flash.create_failed"))
+		ctx.Redirect(302, "/new")
+		return
+	}
+	ctrl.JobList.Invalidate()
+
+	if !job.Confirmed {
+		ctrl.notify(func() { ctrl.sendConfirmation(job) })
+		flash(session, flashInfo, translate(ctx, "flash.confirm_job"))
+	} else if job.NeedsReview || job.Status == data.StatusPending {
+		ctrl.notify(func() { ctrl.notifyJobCreated(job) })
+		flash(session, flashInfo, translate(ctx, "flash.job_submitted"))
+	} else {
+		ctrl.notify(func() { ctrl.notifyJobCreated(job) })
+		flash(session, flashSuccess, translate(ctx, "flash.job_created"))
+	}
 	ctx.Redirect(302, "/")
 }
 
+// HoldJobSubmission responds to posters who trip the submission rate
+// limit with a valid job. Rather than a bare 429, they get the form back
+// with what they entered and a note saying how long to wait.
+func (ctrl *Controller) HoldJobSubmission(ctx *gin.Context, wait time.Duration) {
+	var newJobInput data.NewJob
+	if err := ctx.ShouldBind(&newJobInput); err != nil {
+		log.Println(fmt.Errorf("HoldJobSubmission failed to ctx.ShouldBind: %w", err))
+		ctx.AbortWithStatus(http.StatusTooManyRequests)
+		return
+	}
+	newJobInput.Metadata = metadataFromForm(ctx)
+
+	if errs := newJobInput.Validate(false, ctrl.Config.AllowedMetadataKeys, ctrl.limits(), ctrl.BlockedDomains); len(errs) != 0 {
+		ctx.AbortWithStatus(http.StatusTooManyRequests)
+		return
+	}
+
+	flash(sessions.Default(ctx), flashError, translate(ctx, "flash.rate_limited", retryAfterSeconds(wait)))
+	ctrl.renderNewJob(ctx, http.StatusTooManyRequests, nil, submittedValues(ctx, ctrl.Config.AllowedMetadataKeys))
+}
+
+// limits are how long each job field can be
+func (ctrl *Controller) limits() data.Limits {
+	return data.Limits{
+		Position:     ctrl.Config.MaxPositionLength,
+		Organization: ctrl.Config.MaxOrganizationLength,
+		Url:          data.MaxUrlLength,
+		Description:  ctrl.Config.MaxDescriptionLength,
+		Email:        data.MaxEmailLength,
+		Metadata:     data.MaxMetadataLength,
+	}
+}
+
+// heldFields are the job form fields filled back in when a submission is
+// sent back to the form
+func heldFields(metadataKeys []string) []string {
+	fields := []string{"position", "organization", "url", "description", "email"}
+	for _, k := range metadataKeys {
+		fields = append(fields, fmt.Sprintf("metadata[%s]", k))
+	}
+	return fields
+}
+
+// submittedValues returns what was submitted to the job form, so it can be
+// filled back in. Blank fields are kept too, since on the edit form they
+// mean a value was removed.
+func submittedValues(ctx *gin.Context, metadataKeys []string) map[string]string {
+	values := make(map[string]string)
+	for _, k := range heldFields(metadataKeys) {
+		if v, ok := ctx.GetPostForm(k); ok {
+			values[k] = v
+		}
+	}
+	return values
+}
+
+// newJobFromValues turns values from submittedValues back into a NewJob
+func newJobFromValues(values map[string]string, metadataKeys []string) data.NewJob {
+	newJob := data.NewJob{
+		Position:     values["position"],
+		Organization: values["organization"],
+		Url:          values["url"],
+		Description:  values["description"],
+		Email:        values["email"],
+		Metadata:     data.Metadata{},
+	}
+	for _, k := range metadataKeys {
+		if v := values[fmt.Sprintf("metadata[%s]", k)]; v != "" {
+			newJob.Metadata[k] = v
+		}
+	}
+	return newJob
+}
+
 func (ctrl *Controller) UpdateJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
 	id := ctx.Param("id")
 
 	var newJobInput data.NewJob
@@ -153,44 +450,230 @@ func (ctrl *Controller) UpdateJob(ctx *gin.Context) {
 		}
 	}()
 
-	if errs := newJobInput.Validate(true); len(errs) != 0 {
-		for k, v := range errs {
-			session.AddFlash(v, fmt.Sprintf("%s_err", k))
-		}
+	newJobInput.Metadata = metadataFromForm(ctx)
 
-		token := ctx.Query("token")
-		// TODO: somehow preserve previously provided values?
-		ctx.Redirect(302, fmt.Sprintf("/jobs/%s/edit?token=%s", id, token))
-		return
+	errs := newJobInput.Validate(true, ctrl.Config.AllowedMetadataKeys, ctrl.limits(), ctrl.BlockedDomains)
+
+	var logo *logoUpload
+	if ctrl.FileStore != nil {
+		var logoErr string
+		if logo, logoErr = readLogo(ctx); logoErr != "" {
+			errs["logo"] = logoErr
+		}
 	}
 
-	job, err := data.GetJob(id, ctrl.DB)
-	if err != nil {
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
 		log.Println(fmt.Errorf("failed to getJob: %w", err))
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
+	if len(errs) != 0 {
+		// Show what was submitted, rather than what's saved
+		job.Update(newJobFromValues(submittedValues(ctx, ctrl.Config.AllowedMetadataKeys), ctrl.Config.AllowedMetadataKeys))
+		ctrl.renderEditJob(ctx, http.StatusUnprocessableEntity, job, errs)
+		return
+	}
+
+	if logo != nil {
+		logoUrl, err := ctrl.FileStore.Save(logo.name, bytes.NewReader(logo.content), logo.contentType)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to store logo: %w", err))
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		newJobInput.LogoUrl = logoUrl
+	}
+
 	job.Update(newJobInput)
-	if _, err = job.Save(ctrl.DB); err != nil {
+	if _, err = job.Save(dbCtx, ctrl.DB, ctrl.Config.MaxJobRevisions); err != nil {
 		log.Println(fmt.Errorf("failed to job.save: %w", err))
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	ctrl.JobList.Invalidate()
+	ctrl.recordAudit(ctx, dbCtx, id, data.AuditUpdate)
 
-	session.AddFlash("Job updated!")
+	flash(session, flashSuccess, translate(ctx, "flash.job_updated"))
 	ctx.Redirect(302, "/")
 }
 
-func (ctrl *Controller) ViewJob(ctx *gin.Context) {
+func (ctrl *Controller) ConfirmJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
 	id := ctx.Param("id")
-	job, err := data.GetJob(id, ctrl.DB)
-	if err != nil {
+	session := sessions.Default(ctx)
+
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("failed to getJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if job.Confirmed {
+		flash(session, flashInfo, translate(ctx, "flash.job_already_confirmed"))
+	} else {
+		if err := data.ConfirmJob(dbCtx, id, ctrl.DB); err != nil {
+			log.Println(fmt.Errorf("failed to confirmJob: %w", err))
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		ctrl.JobList.Invalidate()
+		job.Confirmed = true
+
+		ctrl.notify(func() { ctrl.notifyJobCreated(job) })
+		flash(session, flashSuccess, translate(ctx, "flash.job_confirmed"))
+	}
+
+	// Redirects from a GET write a body, so the session has to be saved
+	// before redirecting rather than deferred
+	if err := session.Save(); err != nil {
+		log.Println(fmt.Errorf("ConfirmJob failed to session.Save: %w", err))
+	}
+	// Jobs still waiting on moderation aren't on their page yet
+	if !job.IsPublished() {
+		ctx.Redirect(302, "/")
+		return
+	}
+	ctx.Redirect(302, job.Path())
+}
+
+func (ctrl *Controller) ConfirmDeleteJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("failed to getJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	token := ctx.Query("token")
+	ctx.HTML(200, "delete", addFlash(ctx, gin.H{"job": job, "token": token}))
+}
+
+func (ctrl *Controller) DeleteJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+
+	session := sessions.Default(ctx)
+	defer func() {
+		if err := session.Save(); err != nil {
+			log.Println(fmt.Errorf("DeleteJob failed to session.Save: %w", err))
+		}
+	}()
+
+	job, err := data.GetJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
 		log.Println(fmt.Errorf("failed to getJob: %w", err))
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
+	if err := data.DeleteJob(dbCtx, id, ctrl.DB); errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("failed to deleteJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctrl.JobList.Invalidate()
+	ctrl.recordAudit(ctx, dbCtx, id, data.AuditDelete)
+
+	if job.IsPublished() {
+		// Jobs that were never announced have nothing to follow up on
+		ctrl.notify(func() { ctrl.notifyJobClosed(job) })
+	}
+
+	flash(session, flashSuccess, translate(ctx, "flash.job_deleted"))
+	ctx.Redirect(302, "/")
+}
+
+// NotFound renders the 404 page for routes that don't exist
+func (ctrl *Controller) NotFound(ctx *gin.Context) {
+	if isAPIRequest(ctx) {
+		apiError(ctx, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+	ctx.HTML(http.StatusNotFound, "notfound", addFlash(ctx, gin.H{}))
+}
+
+// ApplyJob counts a click on a job's apply link on the way to its url, so
+// posters can see how many people their listing sent their way
+func (ctrl *Controller) ApplyJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	url, err := data.RecordApply(dbCtx, ctx.Param("id"), ctrl.DB)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to recordApply: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if url == "" {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	// Every click has to reach the server to be counted
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Redirect(http.StatusFound, url)
+}
+
+func (ctrl *Controller) ViewJob(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	id := ctx.Param("id")
+	job, err := data.GetPublishedJob(dbCtx, id, ctrl.DB)
+	if errors.Is(err, data.ErrJobNotFound) {
+		ctrl.NotFound(ctx)
+		return
+	} else if err != nil {
+		log.Println(fmt.Errorf("failed to getPublishedJob: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	// Jobs from before slugs were added don't have one to redirect to
+	if job.Slug != "" && ctx.Param("slug") != job.Slug {
+		location := job.Path()
+		if ctx.Request.URL.RawQuery != "" {
+			location += "?" + ctx.Request.URL.RawQuery
+		}
+		ctx.Redirect(http.StatusMovedPermanently, location)
+		return
+	}
+
+	if setCacheHeaders(ctx, job) {
+		return
+	}
+	if isHead(ctx) {
+		ctx.Status(http.StatusOK)
+		return
+	}
+
 	description, err := job.RenderDescription()
 	if err != nil {
 		log.Println(fmt.Errorf("failed to render job description as markdown: %w", err))
@@ -198,12 +681,238 @@ func (ctrl *Controller) ViewJob(ctx *gin.Context) {
 		// continuing...
 	}
 
-	ctx.HTML(200, "view", gin.H{"job": job, "description": template.HTML(description)})
+	jobURL := ctrl.Config.URL + job.Path()
+
+	jsonLD, err := jobPostingJSONLD(job, description, ctrl.Config.URL)
+	if err != nil {
+		log.Println(fmt.Errorf("failed to build job posting json-ld: %w", err))
+		// continuing...
+	}
+
+	ctx.HTML(200, "view", addFlash(ctx, gin.H{
+		"job":         job,
+		"jobURL":      jobURL,
+		"jsonLD":      jsonLD,
+		"description": template.HTML(description),
+		"metadata":    job.Metadata.Fields(ctrl.Config.AllowedMetadataKeys),
+	}))
+}
+
+func (ctrl *Controller) InboundEmail(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	var email InboundEmail
+	if err := ctx.Bind(&email); err != nil {
+		log.Println(fmt.Errorf("failed to ctx.Bind: %w", err))
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if err := email.Verify(ctrl.Config.InboundEmailKey, time.Now()); err != nil {
+		log.Println(fmt.Errorf("InboundEmail failed to verify: %w", err))
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	newJobInput := email.NewJob()
+	newJobInput.Confirmed = !ctrl.Config.RequireConfirmation
+	if ctrl.Config.RequireApproval {
+		newJobInput.Status = data.StatusPending
+	}
+	if errs := newJobInput.Validate(false, ctrl.Config.AllowedMetadataKeys, ctrl.limits(), ctrl.BlockedDomains); len(errs) != 0 {
+		log.Printf("InboundEmail rejected posting from %q: %v", newJobInput.Email, errs)
+		// 406 tells the provider not to retry the delivery
+		ctx.AbortWithStatus(http.StatusNotAcceptable)
+		return
+	}
+
+	job, err := newJobInput.SaveToDB(dbCtx, ctrl.DB, ctrl.Config.DuplicateSimilarityThreshold)
+	if err != nil {
+		log.Println(fmt.Errorf("InboundEmail failed to save job to db: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctrl.JobList.Invalidate()
+
+	if job.Confirmed {
+		ctrl.notify(func() { ctrl.notifyJobCreated(job) })
+	} else {
+		ctrl.notify(func() { ctrl.sendConfirmation(job) })
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Notifications are retried a few times in case of a transient failure,
+// but all of a job's notifications share notifyBudget so a service that's
+// down doesn't hold up the rest of the queue.
+const (
+	notifyAttempts  = 3
+	notifyBaseDelay = 200 * time.Millisecond
+	notifyBudget    = 3 * time.Second
+)
+
+func (ctrl *Controller) sendConfirmation(job data.Job) {
+	if ctrl.EmailService == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget)
+	defer cancel()
+
+	message := fmt.Sprintf(
+		"Thanks for posting a job!\n\n<a href=\"%s\">Use this link to confirm and publish the job posting</a>",
+		SignedConfirmRoute(job, ctrl.Config),
+	)
+	err := services.Retry(ctx, notifyAttempts, notifyBaseDelay, func() error {
+		return ctrl.EmailService.SendEmail(job.Email, "Confirm your job posting", message)
+	})
+	if err != nil {
+		log.Println(fmt.Errorf("failed to sendEmail: %w", err))
+		// continuing...
+	}
+}
+
+// notifyJobClosed follows up on a job's announcement when it's taken down
+// before it expires, the same as when it does
+func (ctrl *Controller) notifyJobClosed(job data.Job) {
+	if ctrl.SlackService == nil || !ctrl.Config.NotifySlack || !ctrl.Config.SlackExpiryNotices {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget)
+	defer cancel()
+
+	err := services.Retry(ctx, notifyAttempts, notifyBaseDelay, func() error {
+		return ctrl.SlackService.PostExpiredToSlack(job)
+	})
+	if err != nil {
+		log.Println(fmt.Errorf("failed to postExpiredToSlack: %w", err))
+		// continuing...
+	}
+}
+
+func (ctrl *Controller) notifyJobCreated(job data.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyBudget)
+	defer cancel()
+
+	retry := func(fn func() error) error {
+		return services.Retry(ctx, notifyAttempts, notifyBaseDelay, fn)
+	}
+
+	if ctrl.EmailService != nil && ctrl.Config.NotifyEmail {
+		message, err := ctrl.Emails.Render("job_created", gin.H{
+			"job":       job,
+			"editURL":   SignedJobRoute(job, ctrl.Config),
+			"deleteURL": SignedDeleteRoute(job, ctrl.Config),
+		})
+		if err == nil {
+			err = retry(func() error {
+				return ctrl.EmailService.SendEmail(job.Email, "Job Created!", message)
+			})
+		}
+		if err != nil {
+			log.Println(fmt.Errorf("failed to sendEmail: %w", err))
+			// continuing...
+		}
+	}
+
+	if job.NeedsReview || job.Status != data.StatusApproved {
+		// Don't announce jobs that haven't been published yet
+		return
+	}
+
+	ctrl.announceJob(ctx, job)
+}
+
+// announceJob posts a newly published job everywhere it's configured to go
+func (ctrl *Controller) announceJob(ctx context.Context, job data.Job) {
+	retry := func(fn func() error) error {
+		return services.Retry(ctx, notifyAttempts, notifyBaseDelay, fn)
+	}
+
+	if ctrl.SlackService != nil && ctrl.Config.NotifySlack {
+		var ts string
+		err := retry(func() (err error) {
+			ts, err = ctrl.SlackService.PostToSlack(job)
+			return err
+		})
+		if err != nil {
+			log.Println(fmt.Errorf("failed to postToSlack: %w", err))
+			// continuing...
+		} else if ts != "" {
+			dbCtx, cancel := withDBTimeout(context.Background(), ctrl.Config.DBTimeout)
+			defer cancel()
+
+			if err := data.SetJobSlackTS(dbCtx, job.ID, ts, ctrl.DB); err != nil {
+				log.Println(fmt.Errorf("failed to setJobSlackTS: %w", err))
+				// continuing...
+			}
+		}
+	}
+
+	if ctrl.TwitterService != nil && ctrl.Config.NotifyTwitter {
+		if err := retry(func() error { return ctrl.TwitterService.PostToTwitter(job) }); err != nil {
+			log.Println(fmt.Errorf("failed to postToTwitter: %w", err))
+			// continuing...
+		}
+	}
+
+	if ctrl.DiscordService != nil {
+		if err := retry(func() error { return ctrl.DiscordService.PostJobToDiscord(job) }); err != nil {
+			log.Println(fmt.Errorf("failed to postJobToDiscord: %w", err))
+			// continuing...
+		}
+	}
+
+	if ctrl.MastodonService != nil {
+		if err := retry(func() error { return ctrl.MastodonService.PostJobToMastodon(job) }); err != nil {
+			log.Println(fmt.Errorf("failed to postJobToMastodon: %w", err))
+			// continuing...
+		}
+	}
+
+	if ctrl.WebhookService != nil {
+		if err := ctrl.WebhookService.PostJobToWebhooks(job); err != nil {
+			log.Println(fmt.Errorf("failed to postJobToWebhooks: %w", err))
+			// continuing...
+		}
+	}
+}
+
+// dbContext bounds a request's queries by DBTimeout, and cancels them if
+// the client goes away.
+func (ctrl *Controller) dbContext(ctx *gin.Context) (context.Context, context.CancelFunc) {
+	return withDBTimeout(ctx.Request.Context(), ctrl.Config.DBTimeout)
+}
+
+func withDBTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
 }
 
 func addFlash(ctx *gin.Context, base gin.H) gin.H {
 	session := sessions.Default(ctx)
-	base["flashes"] = session.Flashes()
+	base["flashes"] = flashMessages(session)
+	base["locale"] = ctx.GetString(localeKey)
+	if a, ok := ctx.Get(announcementKey); ok {
+		base["announcement"] = a
+	}
 	session.Save()
 	return base
 }
+
+// metadataFromForm collects the metadata[key] form fields, leaving out
+// the ones left blank.
+func metadataFromForm(ctx *gin.Context) data.Metadata {
+	metadata := data.Metadata{}
+	for k, v := range ctx.PostFormMap("metadata") {
+		if v = strings.TrimSpace(v); v != "" {
+			metadata[k] = v
+		}
+	}
+	return metadata
+}
@@ -2,409 +2,3157 @@ package server_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/i18n"
 	"github.com/devict/job-board/pkg/server"
+	"github.com/devict/job-board/pkg/services"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/net/publicsuffix"
 )
 
 // TODO: Things to test:
 // - job deleted after 30 days?
 
+func TestHealth(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.HealthCheckToken = "healthy"
+
+	tests := []struct {
+		dbErr        error
+		token        string
+		expectStatus int
+		expectBody   string
+	}{
+		{token: "", expectStatus: 200, expectBody: ""},
+		{token: "wrong", expectStatus: 200, expectBody: ""},
+		{token: "healthy", expectStatus: 200, expectBody: `{"database":"ok"}`},
+		{dbErr: fmt.Errorf("connection refused"), token: "", expectStatus: 503, expectBody: ""},
+		{dbErr: fmt.Errorf("connection refused"), token: "healthy", expectStatus: 503, expectBody: `{"database":"connection refused"}`},
+	}
+
+	for _, tt := range tests {
+		dbmock.ExpectPing().WillReturnError(tt.dbErr)
+
+		respBody, resp := sendRequest(t, fmt.Sprintf("%s/healthz?token=%s", s.URL, tt.token), nil)
+
+		assert.Equal(t, tt.expectStatus, resp.StatusCode)
+		assert.Equal(t, tt.expectBody, respBody)
+	}
+}
+
+func TestHead(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	jobs := []data.Job{{ID: "1", Position: "Pos 1", PublishedAt: time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		path         string
+		expect       func()
+		cacheControl string
+		etag         bool
+	}{
+		{path: "/healthz", expect: func() { dbmock.ExpectPing() }, cacheControl: "no-store"},
+		{path: "/", expect: func() { expectSelectJobsQuery(dbmock, jobs) }, cacheControl: "private, no-cache", etag: true},
+		{path: "/jobs/1", expect: func() { expectGetJobQuery(dbmock, jobs[0]) }, cacheControl: "private, no-cache", etag: true},
+	}
+
+	for _, tt := range tests {
+		tt.expect()
+		resp, err := http.Head(s.URL + tt.path)
+		if !assert.NoError(t, err, tt.path) {
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		assert.Equal(t, 200, resp.StatusCode, tt.path)
+		assert.Empty(t, body, tt.path)
+		assert.Equal(t, tt.cacheControl, resp.Header.Get("Cache-Control"), tt.path)
+
+		if !tt.etag {
+			continue
+		}
+
+		// GET sends the same tag for the same content
+		etag := resp.Header.Get("ETag")
+		assert.NotEmpty(t, etag, tt.path)
+
+		tt.expect()
+		_, getResp := sendRequest(t, s.URL+tt.path, nil)
+		assert.Equal(t, etag, getResp.Header.Get("ETag"), tt.path)
+	}
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestConditionalGet(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	jobs := []data.Job{{ID: "1", Position: "Pos 1", Organization: "Org", PublishedAt: time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		path   string
+		expect func()
+	}{
+		{path: "/", expect: func() { expectSelectJobsQuery(dbmock, jobs) }},
+		{path: "/jobs/1", expect: func() { expectGetJobQuery(dbmock, jobs[0]) }},
+		{path: "/orgs/Org", expect: func() { expectSelectJobsQuery(dbmock, jobs) }},
+	}
+
+	get := func(path, ifNoneMatch string) (string, *http.Response) {
+		req, err := http.NewRequest(http.MethodGet, s.URL+path, nil)
+		assert.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err, path) {
+			return "", &http.Response{}
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body), resp
+	}
+
+	for _, tt := range tests {
+		tt.expect()
+		_, resp := get(tt.path, "")
+		assert.Equal(t, 200, resp.StatusCode, tt.path)
+		etag := resp.Header.Get("ETag")
+
+		// A current copy isn't sent again
+		tt.expect()
+		body, resp := get(tt.path, `W/"stale", `+etag)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, tt.path)
+		assert.Empty(t, body, tt.path)
+		assert.Equal(t, etag, resp.Header.Get("ETag"), tt.path)
+
+		// A stale one is
+		tt.expect()
+		body, resp = get(tt.path, `W/"stale"`)
+		assert.Equal(t, 200, resp.StatusCode, tt.path)
+		assert.Contains(t, body, "Pos 1", tt.path)
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Pages with a flash waiting are always rendered
+	expectSelectJobsQuery(dbmock, jobs)
+	_, resp := get("/", "")
+	etag := resp.Header.Get("ETag")
+
+	cookieJar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	assert.NoError(t, err)
+	client := http.Client{Jar: cookieJar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnError(errors.New("connection refused"))
+	resp, err = client.PostForm(s.URL+"/jobs", url.Values{"position": {"Pos"}, "organization": {"Org"}, "url": {"https://devict.org"}, "email": {"test@example.com"}})
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	expectSelectJobsQuery(dbmock, jobs)
+	req, err := http.NewRequest(http.MethodGet, s.URL+"/", nil)
+	assert.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = client.Do(req)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, string(body), "Error creating job")
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
 func TestIndex(t *testing.T) {
 	s, _, dbmock, _ := makeServer(t)
 	defer s.Close()
 
-	expectSelectJobsQuery(dbmock, []data.Job{
-		{Position: "Pos 1"},
-		{Position: "Pos 2"},
-	})
+	expectSelectJobsQuery(dbmock, []data.Job{
+		{Position: "Pos 1"},
+		{Position: "Pos 2"},
+	})
+
+	body, _ := sendRequest(t, s.URL, nil)
+
+	assert.Contains(t, string(body), "Pos 1")
+	assert.Contains(t, string(body), "Pos 2")
+	assert.Contains(t, string(body), "2 open positions, updated just now")
+
+	// TODO: What other assertions do we want to make about the home page?
+}
+
+func TestIndexCache(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", JobListCacheTTL: time.Minute})
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "Pos 1", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	// Rapid requests share a single query
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+
+	for i := 0; i < 2; i++ {
+		body, resp := sendRequest(t, s.URL, nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "Pos 1")
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Deleting a job reloads the list
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = .+`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditDelete)
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	route := fmt.Sprintf("%s/jobs/%s/delete?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
+	body, _ := sendRequest(t, route, []byte(""))
+	assert.NotContains(t, body, "Pos 1")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestIndexCacheSlowQuery(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", JobListCacheTTL: time.Minute})
+	defer s.Close()
+
+	rows := func(position string) *sqlmock.Rows {
+		return sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{ID: "1", Position: position})...)
+	}
+	dbmock.ExpectQuery(`SELECT \* FROM jobs .+ ORDER BY featured DESC, published_at DESC`).
+		WillDelayFor(300 * time.Millisecond).
+		WillReturnRows(rows("Newest Pos"))
+	dbmock.ExpectQuery(`SELECT \* FROM jobs .+ ORDER BY featured DESC, published_at ASC`).
+		WillReturnRows(rows("Oldest Pos"))
+
+	// One request gives up on the slow query...
+	impatient := http.Client{Timeout: 50 * time.Millisecond}
+	_, err := impatient.Get(s.URL)
+	assert.Error(t, err)
+
+	// ...which doesn't fail the ones still waiting on it
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		body, resp := sendRequest(t, s.URL, nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "Newest Pos")
+	}()
+
+	// Other sorts aren't held up meanwhile
+	start := time.Now()
+	body, resp := sendRequest(t, s.URL+"/?sort=oldest", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Oldest Pos")
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+	wg.Wait()
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestIndexSummary(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	now := time.Now()
+	expectSelectJobsQuery(dbmock, []data.Job{
+		{Position: "Pos 1", PublishedAt: now.Add(-3 * 24 * time.Hour)},
+		{Position: "Pos 2", PublishedAt: now.Add(-2 * time.Hour)},
+		{Position: "Pos 3", PublishedAt: now.Add(-5 * 24 * time.Hour)},
+	})
+
+	body, _ := sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, "3 open positions, updated 2 hours ago")
+
+	expectSelectJobsQuery(dbmock, []data.Job{{Position: "Pos 1", PublishedAt: now.Add(-3 * 24 * time.Hour)}})
+
+	body, _ = sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, "1 open position, updated 3 days ago")
+
+	expectSelectJobsQuery(dbmock, nil)
+
+	body, _ = sendRequest(t, s.URL, nil)
+	assert.NotContains(t, body, "open position")
+	assert.Contains(t, body, "No job openings posted.")
+}
+
+func TestGzip(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", Gzip: true})
+	defer s.Close()
+
+	get := func(acceptEncoding string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		assert.NoError(t, err)
+		// Setting the header ourselves stops the client from transparently
+		// decompressing the response
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	expectSelectJobsQuery(dbmock, []data.Job{{Position: "Pos 1"}})
+
+	resp := get("gzip, deflate")
+	defer resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+
+	gz, err := gzip.NewReader(resp.Body)
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(gz)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), "Pos 1")
+	}
+
+	expectSelectJobsQuery(dbmock, []data.Job{{Position: "Pos 1"}})
+
+	resp = get("identity")
+	defer resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "Pos 1")
+}
+
+func TestAssets(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", AssetMaxAge: time.Hour})
+	defer s.Close()
+
+	expectSelectJobsQuery(dbmock, nil)
+
+	body, _ := sendRequest(t, s.URL, nil)
+	stylesheet := regexp.MustCompile(`href="(/assets/css/app\.css\?v=[0-9a-f]+)"`).FindStringSubmatch(body)
+	if !assert.Len(t, stylesheet, 2) {
+		return
+	}
+
+	_, resp := sendRequest(t, s.URL+stylesheet[1], nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "public, max-age=3600", resp.Header.Get("Cache-Control"))
+
+	// Images the stylesheet uses are versioned too
+	assert.Regexp(t, `--circuit-board: url\('/assets/svg/circuit-board\.svg\?v=[0-9a-f]+'\)`, body)
+}
+
+func TestFavicon(t *testing.T) {
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", AssetMaxAge: time.Hour})
+	defer s.Close()
+
+	_, resp := sendRequest(t, s.URL+"/favicon.ico", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "image/vnd.microsoft.icon", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "public, max-age=3600", resp.Header.Get("Cache-Control"))
+}
+
+func TestIndexFeatured(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	// Featured jobs come first even when they're older
+	rows := sqlmock.NewRows(getDbFields(data.Job{})).
+		AddRow(mockJobRow(data.Job{ID: "1", Position: "Old Featured Pos", Featured: true, PublishedAt: time.Now().AddDate(0, 0, -20)})...).
+		AddRow(mockJobRow(data.Job{ID: "2", Position: "New Pos", PublishedAt: time.Now()})...)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs .+ ORDER BY featured DESC, published_at DESC`).WillReturnRows(rows)
+
+	body, _ := sendRequest(t, s.URL, nil)
+
+	featured := strings.Index(body, "Old Featured Pos")
+	if assert.NotEqual(t, -1, featured) {
+		assert.Less(t, featured, strings.Index(body, "New Pos"))
+	}
+	assert.Equal(t, 1, strings.Count(body, "Featured</span>"))
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestToggleFeaturedJob(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+	})
+	defer s.Close()
+
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
+	for _, featured := range []bool{true, false} {
+		dbmock.ExpectQuery(`UPDATE jobs SET featured = NOT featured WHERE id = \$1 RETURNING featured`).
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"featured"}).AddRow(featured))
+		if featured {
+			expectAuditRecord(dbmock, "1", "admin", data.AuditFeature)
+		} else {
+			expectAuditRecord(dbmock, "1", "admin", data.AuditUnfeature)
+		}
+		expectAdminQueries(dbmock, nil)
+
+		body, resp := sendRequest(t, adminURL+"/jobs/1/feature", []byte(""))
+		assert.Equal(t, 200, resp.StatusCode)
+		if featured {
+			assert.Contains(t, body, "Job featured!")
+		} else {
+			assert.Contains(t, body, "Job no longer featured!")
+		}
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	}
+
+	dbmock.ExpectQuery(`UPDATE jobs SET featured`).
+		WithArgs("404").
+		WillReturnRows(sqlmock.NewRows([]string{"featured"}))
+
+	_, resp := sendRequest(t, adminURL+"/jobs/404/feature", []byte(""))
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestIndexSort(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	rows := sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{})...)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs .+ ORDER BY featured DESC, published_at ASC`).WillReturnRows(rows)
+
+	body, _ := sendRequest(t, s.URL+"/?sort=oldest", nil)
+	assert.Contains(t, body, `<option value="oldest" selected>`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestIndexSortPersisted(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	cookieJar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	assert.NoError(t, err)
+	client := http.Client{Jar: cookieJar}
+
+	get := func(path, order string) string {
+		rows := sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{})...)
+		dbmock.ExpectQuery(`SELECT \* FROM jobs .+ ORDER BY ` + order).WillReturnRows(rows)
+
+		resp, err := client.Get(s.URL + path)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, dbmock.ExpectationsWereMet(), path)
+		return string(body)
+	}
+
+	get("/?sort=organization", `featured DESC, lower\(organization\)`)
+
+	// Coming back without ?sort= keeps the sort that was picked
+	body := get("/", `featured DESC, lower\(organization\)`)
+	assert.Contains(t, body, `<option value="organization" selected>`)
+
+	// Until another is picked
+	get("/?sort=newest", `featured DESC, published_at DESC`)
+	body = get("/", `featured DESC, published_at DESC`)
+	assert.Contains(t, body, `<option value="newest" selected>`)
+}
+
+func TestViewOrganization(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	org := "Acme/Widgets & Co?"
+	rows := sqlmock.NewRows(getDbFields(data.Job{})).
+		AddRow(mockJobRow(data.Job{ID: "1", Position: "Pos 1", Organization: org})...).
+		AddRow(mockJobRow(data.Job{ID: "2", Position: "Pos 2", Organization: org})...)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE organization = \$1`).WithArgs(org).WillReturnRows(rows)
+
+	body, resp := sendRequest(t, s.URL+"/orgs/"+url.PathEscape(org), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Jobs at Acme/Widgets &amp; Co?")
+	assert.Contains(t, body, "Pos 1")
+	assert.Contains(t, body, "Pos 2")
+	assert.Contains(t, body, `href="/orgs/Acme%2FWidgets%20&amp;%20Co%3F"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE organization = \$1`).
+		WithArgs("Nobody").
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+
+	_, resp = sendRequest(t, s.URL+"/orgs/Nobody", nil)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestIndexDBTimeout(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.DBTimeout = 50 * time.Millisecond
+
+	rows := sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{})...)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs`).WillDelayFor(time.Second).WillReturnRows(rows)
+
+	start := time.Now()
+	_, resp := sendRequest(t, s.URL, nil)
+
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestIndexLoadShedding(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:                  "sup",
+		Env:                        "debug",
+		MaxConcurrentHeavyRequests: 1,
+	})
+	defer s.Close()
+
+	// The first request holds the only slot while its query is slow
+	rows := sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{Position: "Pos 1"})...)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs`).WillDelayFor(200 * time.Millisecond).WillReturnRows(rows)
+	// Expectations can't be added while the query is in flight
+	dbmock.ExpectPing()
+
+	done := make(chan int)
+	go func() {
+		_, resp := sendRequest(t, s.URL, nil)
+		done <- resp.StatusCode
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	_, resp := sendRequest(t, s.URL, nil)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Equal(t, "5", resp.Header.Get("Retry-After"))
+
+	// Health checks are exempt
+	_, resp = sendRequest(t, fmt.Sprintf("%s/healthz", s.URL), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Equal(t, 200, <-done)
+}
+
+func TestAnnouncement(t *testing.T) {
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:        "sup",
+		Env:              "debug",
+		AnnouncementText: "Job fair this Friday",
+		AnnouncementURL:  "https://devict.org/jobfair",
+	})
+	defer s.Close()
+
+	body, _ := sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+	assert.Contains(t, body, "Job fair this Friday")
+	assert.Contains(t, body, `href="https://devict.org/jobfair"`)
+	assert.Contains(t, body, `name="next" value="/new"`)
+
+	// Dismissing it sends us back to the page without the banner
+	body, resp := sendRequest(t, fmt.Sprintf("%s/announcement/dismiss", s.URL), []byte(url.Values{"next": {"/new"}}.Encode()))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/new", resp.Request.URL.Path)
+	assert.NotContains(t, body, "Job fair this Friday")
+}
+
+func TestNoAnnouncement(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	body, _ := sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+	assert.NotContains(t, body, "announcement")
+}
+
+func TestRedisSessions(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:        "sup",
+		Env:              "debug",
+		RedisURL:         "redis://" + mr.Addr(),
+		AnnouncementText: "Job fair this Friday",
+	})
+	defer s.Close()
+
+	// Dismissing the banner is remembered in the session on the redirect
+	body, resp := sendRequest(t, fmt.Sprintf("%s/announcement/dismiss", s.URL), []byte(url.Values{"next": {"/new"}}.Encode()))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/new", resp.Request.URL.Path)
+	assert.NotContains(t, body, "Job fair this Friday")
+
+	// The session itself is in redis, with only its id in the cookie
+	keys := mr.Keys()
+	if assert.Len(t, keys, 1) {
+		assert.True(t, strings.HasPrefix(keys[0], "session_"), keys[0])
+		assert.Greater(t, mr.TTL(keys[0]), time.Duration(0))
+	}
+}
+
+func TestDBSessions(t *testing.T) {
+	db, dbmock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	dbmock.ExpectExec(`CREATE TABLE IF NOT EXISTS http_sessions`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	conf := &config.Config{AppSecret: "sup", Env: "debug", DBSessions: true}
+	s, err := server.NewServer(&server.ServerConfig{
+		Config:       conf,
+		DB:           db,
+		TemplatePath: "../../templates",
+		AssetPath:    "../../assets",
+	})
+	assert.NoError(t, err)
+
+	testServer := httptest.NewServer(s.Handler)
+	defer testServer.Close()
+
+	cookieJar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	assert.NoError(t, err)
+	client := http.Client{
+		Jar: cookieJar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// A job that fails to save is sent back to the form with a flash,
+	// which is saved to the database rather than the cookie
+	var key, sessionData string
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnError(errors.New("connection refused"))
+	dbmock.ExpectExec(`INSERT INTO http_sessions`).
+		WithArgs(captureArg{&key}, captureArg{&sessionData}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	resp, err := client.PostForm(testServer.URL+"/jobs", url.Values{"position": {"Pos"}, "organization": {"Org"}, "url": {"https://devict.org"}, "email": {"test@example.com"}})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 302, resp.StatusCode)
+	assert.Equal(t, "/new", resp.Header.Get("Location"))
+	assert.NotEmpty(t, key)
+	assert.NotContains(t, resp.Header.Get("Set-Cookie"), sessionData)
+
+	// Following the redirect loads them back out
+	dbmock.ExpectQuery(`SELECT (.+) FROM http_sessions WHERE key = \$1`).
+		WithArgs(key).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "key", "data", "created_on", "modified_on", "expires_on"}).
+				AddRow(1, key, sessionData, time.Now(), time.Now(), time.Now().Add(time.Hour)),
+		)
+	dbmock.ExpectExec(`UPDATE http_sessions SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	resp, err = client.Get(testServer.URL + "/new")
+	assert.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(body), "Error creating job")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestSessionCookie(t *testing.T) {
+	tests := []struct {
+		conf       *config.Config
+		expectName string
+		expectAge  int
+	}{
+		{&config.Config{AppSecret: "sup", Env: "debug", SessionCookieName: "jobs_session", SessionMaxAge: 2 * time.Hour}, "jobs_session", 7200},
+		{&config.Config{AppSecret: "sup", Env: "debug"}, "mysession", 86400},
+	}
+
+	for _, tt := range tests {
+		s, _, _, _ := makeServerWithConfig(t, tt.conf)
+		defer s.Close()
+
+		client := http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.PostForm(s.URL+"/announcement/dismiss", url.Values{"next": {"/new"}})
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		cookies := resp.Cookies()
+		if assert.Len(t, cookies, 1, tt.expectName) {
+			assert.Equal(t, tt.expectName, cookies[0].Name)
+			assert.Equal(t, tt.expectAge, cookies[0].MaxAge)
+		}
+	}
+}
+
+func TestAdminIndex(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+	})
+	defer s.Close()
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/admin", s.URL), nil)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
+	expectAdminQueries(dbmock, []data.Job{{ID: "2", Position: "Deleted Pos", DeletedAt: sql.NullTime{Time: time.Now(), Valid: true}}})
+
+	body, resp := sendRequest(t, adminURL, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "<td class=\"text-right\">12</td>")
+	assert.Contains(t, body, "<td class=\"text-right\">4</td>")
+	assert.Contains(t, body, "<td class=\"text-right\">9</td>")
+	assert.Contains(t, body, "Busy Org")
+	assert.Contains(t, body, "Expiring Pos")
+	assert.Contains(t, body, "Deleted Pos")
+	assert.Contains(t, body, `action="/admin/jobs/2/restore"`)
+	assert.Contains(t, body, `<a href="/jobs/4" class="hover:underline focus:underline">Audited Pos</a>`)
+	assert.Contains(t, body, "<td>#5</td>")
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAdminCrossSite(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+	})
+	defer s.Close()
+
+	conf.URL = "https://jobs.devict.org"
+	route := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin/jobs/1/approve"
+
+	tests := []struct {
+		origin  string
+		referer string
+		want    int
+	}{
+		{origin: "https://evil.example", want: http.StatusForbidden},
+		{referer: "https://evil.example/admin", want: http.StatusForbidden},
+		{origin: "null", want: http.StatusForbidden},
+		{want: http.StatusForbidden},
+		// APP_URL counts as the board's own, e.g. behind a proxy
+		{origin: "https://jobs.devict.org", want: http.StatusNotFound},
+		{referer: s.URL + "/admin", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if tt.want == http.StatusNotFound {
+			dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1`).
+				WithArgs("1").
+				WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, route, nil)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.referer != "" {
+			req.Header.Set("Referer", tt.referer)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode, tt)
+		}
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestDeleteAndRestoreJob(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+	})
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "Soft Deleted Pos", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	// Deleting only hides the job, and the index leaves it out
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditDelete)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE confirmed AND NOT needs_review .*AND deleted_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+
+	route := fmt.Sprintf("%s/jobs/%s/delete?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
+	body, _ := sendRequest(t, route, []byte(""))
+	assert.Contains(t, body, "Job deleted!")
+	assert.NotContains(t, body, job.Position)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Restoring it brings it back
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/admin/jobs/%s/restore", s.URL, job.ID), []byte(""))
+	assert.Equal(t, 401, resp.StatusCode)
+
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NULL WHERE id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, "admin", data.AuditRestore)
+	expectAdminQueries(dbmock, nil)
+
+	body, resp = sendRequest(t, fmt.Sprintf("%s/jobs/%s/restore", adminURL, job.ID), []byte(""))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Job restored!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+	body, _ = sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, job.Position)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAdminAccounts(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+		AdminAccounts: map[string]string{"alice": string(hash)},
+	})
+	defer s.Close()
+
+	tests := []struct {
+		credentials  string
+		expectStatus int
+	}{
+		{credentials: "alice:correct%20horse", expectStatus: 200},
+		// the single user still works alongside the accounts
+		{credentials: "admin:hunter2", expectStatus: 200},
+		{credentials: "alice:hunter2", expectStatus: 401},
+		// the hash itself isn't a password
+		{credentials: "alice:" + url.PathEscape(string(hash)), expectStatus: 401},
+		{credentials: "admin:correct%20horse", expectStatus: 401},
+		{credentials: "bob:correct%20horse", expectStatus: 401},
+	}
+
+	for _, tt := range tests {
+		if tt.expectStatus == 200 {
+			expectAdminQueries(dbmock, nil)
+		}
+
+		adminURL := strings.Replace(s.URL, "http://", "http://"+tt.credentials+"@", 1) + "/admin"
+		_, resp := sendRequest(t, adminURL, nil)
+		assert.Equal(t, tt.expectStatus, resp.StatusCode, tt.credentials)
+	}
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestRevertJob(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:       "sup",
+		Env:             "debug",
+		AdminUser:       "admin",
+		AdminPassword:   "hunter2",
+		MaxJobRevisions: 5,
+	})
+	defer s.Close()
+
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+	revisionFields := []string{"id", "job_id", "position", "organization", "url", "description", "metadata", "logo_url", "created_at"}
+
+	job := data.Job{ID: "1", Position: "Old Pos", Organization: "Org", Email: "secret@secret.com", PublishedAt: time.Now()}
+	oldUrl := sql.NullString{String: "https://devict.org/old", Valid: true}
+	job.Url = oldUrl
+
+	// Updating keeps the old version as a revision
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectBegin()
+	dbmock.ExpectExec(`INSERT INTO job_revisions .+ FROM jobs WHERE id = \$1`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbmock.ExpectExec(`DELETE FROM job_revisions`).
+		WithArgs(job.ID, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = \$8`).
+		WithArgs("New Pos", "Org", sql.NullString{String: "https://devict.org/new", Valid: true}, sql.NullString{}, "{}", sql.NullString{}, "new-pos-org", job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.ExpectCommit()
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditUpdate)
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	values := url.Values{
+		"position":     {"New Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org/new"},
+	}
+	route := fmt.Sprintf("%s/jobs/%s?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
+	body, resp := sendRequest(t, route, []byte(values.Encode()))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Job updated!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// It's listed with a way to revert to it
+	updated := job
+	updated.Position = "New Pos"
+	updated.Url = sql.NullString{String: "https://devict.org/new", Valid: true}
+	revisionRow := []driver.Value{"7", job.ID, "Old Pos", "Org", oldUrl, nil, "{}", nil, time.Now()}
+
+	expectGetJobQuery(dbmock, updated)
+	dbmock.ExpectQuery(`SELECT \* FROM job_revisions WHERE job_id = \$1 ORDER BY id DESC`).
+		WithArgs(job.ID).
+		WillReturnRows(sqlmock.NewRows(revisionFields).AddRow(revisionRow...))
+
+	body, resp = sendRequest(t, fmt.Sprintf("%s/jobs/%s/revisions", adminURL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Old Pos")
+	assert.Contains(t, body, "https://devict.org/old")
+	assert.Contains(t, body, "https://devict.org/new")
+	assert.Contains(t, body, `action="/admin/jobs/1/revisions/7/revert"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Reverting restores the old values, keeping the new ones as a revision
+	expectGetJobQuery(dbmock, updated)
+	dbmock.ExpectQuery(`SELECT \* FROM job_revisions WHERE id = \$1 AND job_id = \$2`).
+		WithArgs("7", job.ID).
+		WillReturnRows(sqlmock.NewRows(revisionFields).AddRow(revisionRow...))
+	dbmock.ExpectBegin()
+	dbmock.ExpectExec(`INSERT INTO job_revisions`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	dbmock.ExpectExec(`DELETE FROM job_revisions`).
+		WithArgs(job.ID, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = \$8`).
+		WithArgs("Old Pos", "Org", oldUrl, sql.NullString{}, "{}", sql.NullString{}, "old-pos-org", job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbmock.ExpectCommit()
+	expectAuditRecord(dbmock, job.ID, "admin", data.AuditRevert)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectQuery(`SELECT \* FROM job_revisions WHERE job_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(revisionFields))
+
+	body, resp = sendRequest(t, fmt.Sprintf("%s/jobs/%s/revisions/7/revert", adminURL, job.ID), []byte(""))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Job reverted!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Revisions of other jobs can't be applied
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectQuery(`SELECT \* FROM job_revisions WHERE id = \$1 AND job_id = \$2`).
+		WithArgs("9", job.ID).
+		WillReturnRows(sqlmock.NewRows(revisionFields))
+
+	_, resp = sendRequest(t, fmt.Sprintf("%s/jobs/%s/revisions/9/revert", adminURL, job.ID), []byte(""))
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestAdminDisabled(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/admin", s.URL), nil)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestEmbedJobs(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.EmbedOrigins = []string{"https://devict.org", "https://*.devict.org"}
+
+	jobs := []data.Job{
+		{ID: "1", Position: "Pos 1", Organization: "Org 1"},
+		{ID: "2", Position: "Pos 2", Organization: "Org 2"},
+		{ID: "3", Position: "Pos 3", Organization: "Org 3"},
+	}
+	expectSelectJobsQuery(dbmock, jobs)
+
+	body, resp := sendRequest(t, fmt.Sprintf("%s/embed/jobs?limit=2", s.URL), nil)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Pos 1")
+	assert.Contains(t, body, "Org 2")
+	assert.NotContains(t, body, "Pos 3")
+	assert.Contains(t, body, fmt.Sprintf(`href="%s/jobs/1" target="_blank"`, s.URL))
+
+	// Only the configured sites may frame it, and it can't load anything
+	csp := resp.Header.Get("Content-Security-Policy")
+	assert.Contains(t, csp, "default-src 'none'")
+	assert.Contains(t, csp, "frame-ancestors https://devict.org https://*.devict.org")
+	assert.Empty(t, resp.Header.Get("X-Frame-Options"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Empty(t, resp.Header.Get("Set-Cookie"))
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestEmbedScript(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	body, resp := sendRequest(t, fmt.Sprintf("%s/embed/jobs.js", s.URL), nil)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/javascript; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, fmt.Sprintf(`frame.src = "%s" + "/embed/jobs"`, s.URL))
+}
+
+func TestIndexGroupByOrg(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	// Links to each job's page, leaving out their apply links
+	jobLinks := regexp.MustCompile(`href="/jobs/\d+"`)
+
+	jobs := []data.Job{
+		{ID: "1", Position: "Pos 1", Organization: "Big Co"},
+		{ID: "2", Position: "Pos 2", Organization: "Small Co"},
+		{ID: "3", Position: "Pos 3", Organization: "Big Co"},
+	}
+
+	// Flat by default
+	expectSelectJobsQuery(dbmock, jobs)
+	body, _ := sendRequest(t, s.URL, nil)
+
+	assert.NotContains(t, body, "<details>")
+	assert.Len(t, jobLinks.FindAllString(body, -1), 3)
+
+	// Grouped when enabled
+	conf.GroupByOrg = true
+	expectSelectJobsQuery(dbmock, jobs)
+	body, _ = sendRequest(t, s.URL, nil)
+
+	assert.Equal(t, 1, strings.Count(body, "<details>"))
+	assert.Regexp(t, `(?s)<details>.*Big Co.*2 jobs.*Pos 1.*Pos 3.*</details>`, body)
+	assert.Contains(t, body, "Pos 2")
+	assert.Len(t, jobLinks.FindAllString(body, -1), 3)
+}
+
+func TestNewJob(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	body, resp := sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// - assert that all the right fields are present
+	tests := []struct {
+		field    string
+		required bool
+		textArea bool
+	}{
+		{"position", true, false},
+		{"organization", true, false},
+		{"description", false, true},
+		{"url", false, false},
+		{"email", true, false},
+	}
+
+	for _, tt := range tests {
+		reqStr := ""
+		if tt.required {
+			reqStr = "required.*"
+		}
+
+		base := "input"
+		if tt.textArea {
+			base = "textarea"
+		}
+
+		r := fmt.Sprintf(`<%s.+name="%s".*%s>`, base, tt.field, reqStr)
+		assert.Regexp(t, regexp.MustCompile(r), body)
+	}
+}
+
+func TestCreateJob(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	tests := []struct {
+		values            map[string][]string
+		expectSuccess     bool
+		expectErrMessages []string
+	}{
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {""},
+				"url":          {"https://devict.org"},
+				"email":        {"test@example.com"},
+			},
+			expectSuccess: true,
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {"Super rad place to work"},
+				"url":          {""},
+				"email":        {"test@example.com"},
+			},
+			expectSuccess: true,
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {""},
+				"url":          {""},
+				"email":        {"test@example.com"},
+			},
+			expectSuccess:     false,
+			expectErrMessages: []string{en(data.ErrNoUrlOrDescription)},
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {"Cool cool cool"},
+				"url":          {""},
+				"email":        {""},
+			},
+			expectSuccess:     false,
+			expectErrMessages: []string{en(data.ErrNoEmail)},
+		},
+	}
+
+	for _, tt := range tests {
+		newJob := data.Job{
+			ID:           "1",
+			Position:     tt.values["position"][0],
+			Organization: tt.values["organization"][0],
+			Description:  sql.NullString{String: tt.values["description"][0], Valid: true},
+			Url:          sql.NullString{String: tt.values["url"][0], Valid: true},
+			Email:        tt.values["email"][0],
+			PublishedAt:  time.Now(),
+			Confirmed:    true,
+			Status:       data.StatusApproved,
+		}
+
+		if tt.expectSuccess {
+			dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnRows(
+				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(newJob)...),
+			)
+
+			expectSelectJobsQuery(dbmock, []data.Job{newJob})
+		}
+
+		reqBody := url.Values(tt.values).Encode()
+		respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+		svcmock.flush()
+
+		// Successes redirect to the index, failures get the form back
+		if tt.expectSuccess {
+			assert.Equal(t, 200, resp.StatusCode)
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		if tt.expectSuccess {
+			assert.Contains(t, respBody, tt.values["position"][0])
+			assert.Contains(t, respBody, tt.values["organization"][0])
+
+			assert.Equal(t, 1, len(svcmock.emails))
+			assert.Equal(t, 1, len(svcmock.tweets))
+			assert.Equal(t, 1, len(svcmock.slacks))
+			assert.Equal(t, 1, len(svcmock.discords))
+			assert.Equal(t, 1, len(svcmock.toots))
+
+			assert.Equal(t, "Job Created!", svcmock.emails[0].subject)
+			assert.Equal(t, tt.values["email"][0], svcmock.emails[0].recipient)
+			assert.Contains(t, svcmock.emails[0].body, server.SignedJobRoute(newJob, conf))
+			assert.Contains(t, svcmock.emails[0].body, server.SignedDeleteRoute(newJob, conf))
+
+			assert.Contains(t, svcmock.tweets, newJob)
+			assert.Contains(t, svcmock.slacks, newJob)
+			assert.Contains(t, svcmock.discords, newJob)
+			assert.Contains(t, svcmock.toots, newJob)
+			assert.Contains(t, svcmock.webhooks, newJob)
+		} else {
+			for _, errMsg := range tt.expectErrMessages {
+				assert.Contains(t, respBody, errMsg)
+			}
+			assert.Empty(t, svcmock.emails)
+			assert.Empty(t, svcmock.tweets)
+			assert.Empty(t, svcmock.slacks)
+			assert.Empty(t, svcmock.discords)
+			assert.Empty(t, svcmock.toots)
+			assert.Empty(t, svcmock.webhooks)
+		}
+
+		resetServiceMock(svcmock)
+	}
+}
+
+func TestPreviewDescription(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	description := "**Rad** job at https://devict.org\n\n<script>alert(1)</script>\n\n[click me](javascript:alert(1))"
+	body, resp := sendRequest(t, s.URL+"/preview", []byte(url.Values{"description": {description}}.Encode()))
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, "<strong>Rad</strong>")
+	assert.Contains(t, body, `<a href="https://devict.org">https://devict.org</a>`)
+	assert.NotContains(t, body, "<script>")
+	assert.NotContains(t, body, "javascript:")
+
+	_, resp = sendRequest(t, s.URL+"/preview", []byte(""))
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestViewJob(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	tests := []struct {
+		job data.Job
+	}{
+		{
+			job: data.Job{
+				ID:           "1",
+				Position:     "Pos",
+				Organization: "Org",
+				Description:  sql.NullString{String: "Coolest job eva", Valid: true},
+				Url:          sql.NullString{},
+				Email:        "test@example.com",
+			},
+		},
+		{
+			job: data.Job{
+				ID:           "2",
+				Position:     "Pos 2",
+				Organization: "Org 2",
+				Description:  sql.NullString{},
+				Url:          sql.NullString{String: "https://devict.org", Valid: true},
+				Email:        "test@example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		expectGetJobQuery(dbmock, tt.job)
+
+		respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, tt.job.ID), nil)
+
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, respBody, tt.job.Position)
+		assert.Contains(t, respBody, tt.job.Organization)
+
+		if tt.job.Description.Valid {
+			assert.Contains(t, respBody, tt.job.Description.String)
+		}
+
+		if tt.job.Url.Valid {
+			assert.Contains(t, respBody, tt.job.Url.String)
+		}
+
+		assert.NotContains(t, respBody, tt.job.Email) // Don't expose the email!
+	}
+}
+
+func TestViewJobEscapesFields(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	// Jobs saved before markup was rejected are still shown as text
+	job := data.Job{
+		ID:           "1",
+		Position:     `<img src=x onerror="alert(1)">Pos`,
+		Organization: "<b>Org</b>",
+		Email:        "test@example.com",
+	}
+	expectGetJobQuery(dbmock, job)
+
+	body, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NotContains(t, body, "<img src=x")
+	assert.NotContains(t, body, "<b>Org</b>")
+	assert.Contains(t, body, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;Pos")
+	assert.Contains(t, body, "&lt;b&gt;Org&lt;/b&gt;")
+}
+
+func TestViewJobNotFound(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1`).
+		WithArgs("404").
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+
+	body, resp := sendRequest(t, s.URL+"/jobs/404", nil)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Contains(t, body, "Page not found")
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1`).
+		WithArgs("404").
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+
+	_, resp = sendRequest(t, s.URL+"/jobs/404/edit?token=nope", nil)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestViewJobUnpublished(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	// A pending job isn't returned by the published-only query
+	for _, path := range []string{"/jobs/1", "/jobs/1/a-slug"} {
+		dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE id = \$1 AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL`).
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})))
+
+		body, resp := sendRequest(t, s.URL+path, nil)
+		assert.Equal(t, 404, resp.StatusCode, path)
+		assert.NotContains(t, body, "application/ld+json", path)
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestApplyJob(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	// Don't follow the redirect off to the job's site
+	client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	// Each click is counted on the way through
+	for i := 0; i < 2; i++ {
+		dbmock.ExpectQuery(`UPDATE jobs SET apply_count = apply_count \+ 1\s+WHERE id = \$1 AND url IS NOT NULL\s+AND confirmed AND NOT needs_review AND status = 'approved' AND deleted_at IS NULL\s+RETURNING url`).
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"url"}).AddRow("https://devict.org/careers?role=1"))
+
+		resp, err := client.Get(s.URL + "/jobs/1/apply")
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, 302, resp.StatusCode)
+			assert.Equal(t, "https://devict.org/careers?role=1", resp.Header.Get("Location"))
+			assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+		}
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Jobs with only a description have nowhere to send anyone, and
+	// unpublished jobs aren't matched by the update either
+	dbmock.ExpectQuery(`UPDATE jobs SET apply_count`).
+		WithArgs("2").
+		WillReturnRows(sqlmock.NewRows([]string{"url"}))
+
+	resp, err := client.Get(s.URL + "/jobs/2/apply")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 404, resp.StatusCode)
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Listings link through it rather than straight to the job's site
+	expectSelectJobsQuery(dbmock, []data.Job{{ID: "1"}, {ID: "2", Url: sql.NullString{}, Description: sql.NullString{String: "Desc", Valid: true}}})
+	body, _ := sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, `href="/jobs/1/apply"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestNotFound(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	for _, path := range []string{"/nope.txt", "/jobs/1/apply/nope"} {
+		body, resp := sendRequest(t, s.URL+path, nil)
+		assert.Equal(t, 404, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+		assert.Contains(t, body, "Page not found")
+		assert.Contains(t, body, `<a href="/" class="btn btn-secondary">Back to the job board</a>`)
+		// it's rendered inside the usual layout
+		assert.Contains(t, body, "Post a job")
+	}
+}
+
+func TestServerErrorPage(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	dbmock.ExpectQuery(`SELECT \* FROM jobs`).WillReturnError(fmt.Errorf("pq: connection to 10.0.0.5 refused"))
+
+	body, resp := sendRequest(t, s.URL+"/jobs/1", nil)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, body, "Something went wrong")
+	assert.Contains(t, body, "Post a job")
+	assert.NotContains(t, body, "10.0.0.5")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// The page shows the same request ID as the header, to quote in reports
+	requestID := resp.Header.Get("X-Request-ID")
+	if assert.NotEmpty(t, requestID) {
+		assert.Contains(t, body, "include this request ID: "+requestID)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	s, _, _, _ := makeServer(t)
+	defer s.Close()
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	_, first := sendRequest(t, s.URL+"/new", nil)
+	_, second := sendRequest(t, s.URL+"/new", nil)
+	assert.Regexp(t, uuidPattern, first.Header.Get("X-Request-ID"))
+	assert.Regexp(t, uuidPattern, second.Header.Get("X-Request-ID"))
+	assert.NotEqual(t, first.Header.Get("X-Request-ID"), second.Header.Get("X-Request-ID"))
+	assert.Len(t, first.Header.Values("X-Request-ID"), 1)
+
+	// An ID from a proxy in front of us is kept, unless it's junk
+	for incoming, kept := range map[string]bool{"abc-123.def_456": true, "bad id; X-Evil: 1": false, strings.Repeat("a", 65): false} {
+		req, err := http.NewRequest("GET", s.URL+"/new", nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Request-ID", incoming)
+
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		resp.Body.Close()
+
+		if kept {
+			assert.Equal(t, incoming, resp.Header.Get("X-Request-ID"))
+		} else {
+			assert.Regexp(t, uuidPattern, resp.Header.Get("X-Request-ID"))
+		}
+	}
+}
+
+func TestViewJobSlug(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "Senior Go Engineer", Organization: "Acme, Inc.", Slug: "senior-go-engineer-acme-inc"}
+
+	client := http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	// Missing and outdated slugs are sent to the current one
+	for _, path := range []string{"/jobs/1", "/jobs/1/old-position-acme-inc"} {
+		expectGetJobQuery(dbmock, job)
+
+		resp, err := client.Get(s.URL + path + "?utm_source=slack")
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+			assert.Equal(t, 301, resp.StatusCode)
+			assert.Equal(t, "/jobs/1/senior-go-engineer-acme-inc?utm_source=slack", resp.Header.Get("Location"))
+		}
+	}
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	expectGetJobQuery(dbmock, job)
+	body, resp := sendRequest(t, s.URL+"/jobs/1/senior-go-engineer-acme-inc", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Senior Go Engineer")
+	assert.Contains(t, body, `href="/jobs/1/senior-go-engineer-acme-inc"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Jobs whose slug would be a route under /jobs/:id/ can still be reached
+	reserved := data.Job{ID: "3", Position: "Apply", Organization: "!!!", Slug: data.Slugify("Apply", "!!!")}
+	expectGetJobQuery(dbmock, reserved)
+	body, resp = sendRequest(t, s.URL+reserved.Path(), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, "Apply")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Jobs posted before slugs keep working without one
+	expectGetJobQuery(dbmock, data.Job{ID: "2"})
+	_, resp = sendRequest(t, s.URL+"/jobs/2", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Listings link straight to the slugged url
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+	body, _ = sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, `href="/jobs/1/senior-go-engineer-acme-inc"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestViewJobMetaTags(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Description: sql.NullString{
+			String: "## About us\n\nWe build **cool** things with [Go](https://go.dev).\n\n" +
+				strings.Repeat("More words about the role. ", 20),
+			Valid: true,
+		},
+		Email: "test@example.com",
+	}
+
+	expectGetJobQuery(dbmock, job)
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Contains(t, respBody, `<meta property="og:type" content="article">`)
+	assert.Contains(t, respBody, `<meta property="og:title" content="Pos @ Org">`)
+	assert.Contains(t, respBody, fmt.Sprintf(`<meta property="og:url" content="%s/jobs/1">`, conf.URL))
+	assert.Contains(t, respBody, `<meta name="twitter:card" content="summary">`)
+	assert.Regexp(t, `<meta property="og:description" content="About us We build cool things with Go\. More words[^"<>*#\[]*…">`, respBody)
+}
+
+func TestViewJobMetadata(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.AllowedMetadataKeys = []string{"visa_sponsorship", "équipe"}
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Metadata: data.Metadata{
+			"visa_sponsorship": "Available",
+			"équipe":           "Platform",
+			"favorite_color":   "Blue",
+		},
+	}
+
+	expectGetJobQuery(dbmock, job)
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Visa sponsorship")
+	assert.Contains(t, respBody, "Available")
+	assert.Contains(t, respBody, "Équipe")
+
+	// Keys that aren't allowed (anymore) aren't shown
+	assert.NotContains(t, respBody, "Blue")
+}
+
+func TestCreateJobNotifyFlags(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		notifyEmail, notifySlack, notifyTweet bool
+	}{
+		{"all enabled", true, true, true},
+		{"email disabled", false, true, true},
+		{"slack disabled", true, false, true},
+		{"twitter disabled", true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, svcmock, dbmock, _ := makeServerWithConfig(t, &config.Config{
+				AppSecret:     "sup",
+				Env:           "debug",
+				NotifyEmail:   tt.notifyEmail,
+				NotifySlack:   tt.notifySlack,
+				NotifyTwitter: tt.notifyTweet,
+			})
+			defer s.Close()
+
+			newJob := data.Job{
+				ID:           "1",
+				Position:     "Pos",
+				Organization: "Org",
+				Url:          sql.NullString{String: "https://devict.org", Valid: true},
+				Email:        "test@example.com",
+				PublishedAt:  time.Now(),
+				Confirmed:    true,
+				Status:       data.StatusApproved,
+			}
+			dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnRows(
+				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(newJob)...),
+			)
+			expectSelectJobsQuery(dbmock, []data.Job{newJob})
+
+			reqBody := url.Values{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"url":          {"https://devict.org"},
+				"email":        {"test@example.com"},
+			}.Encode()
+			_, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+			svcmock.flush()
+			assert.Equal(t, 200, resp.StatusCode)
+
+			assert.Equal(t, tt.notifyEmail, len(svcmock.emails) == 1, "emails")
+			assert.Equal(t, tt.notifySlack, len(svcmock.slacks) == 1, "slacks")
+			assert.Equal(t, tt.notifyTweet, len(svcmock.tweets) == 1, "tweets")
+
+			// Channels without a flag aren't affected
+			assert.Len(t, svcmock.discords, 1)
+			assert.Len(t, svcmock.toots, 1)
+		})
+	}
+}
+
+func TestCreateJobMetadata(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.AllowedMetadataKeys = []string{"visa_sponsorship"}
+
+	values := url.Values{
+		"position":                   {"Pos"},
+		"organization":               {"Org"},
+		"url":                        {"https://devict.org"},
+		"email":                      {"test@example.com"},
+		"metadata[visa_sponsorship]": {"Available"},
+		"metadata[favorite_color]":   {"Blue"},
+	}
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(t, respBody, en(data.ErrInvalidMetadata))
+	assert.Contains(t, respBody, `name="metadata[visa_sponsorship]"`)
+
+	values.Del("metadata[favorite_color]")
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+		"Pos",
+		"Org",
+		sql.NullString{String: "https://devict.org", Valid: true},
+		sql.NullString{},
+		"test@example.com",
+		false,
+		sql.NullString{},
+		true,
+		`{"visa_sponsorship":"Available"}`,
+		"approved",
+		"pos-org",
+	).WillReturnRows(
+		sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{Confirmed: true})...),
+	)
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	_, resp = sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestViewJobDisplayTimezone(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	assert.NoError(t, err)
+
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:       "sup",
+		Env:             "debug",
+		DisplayLocation: chicago,
+	})
+	defer s.Close()
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Email:        "test@example.com",
+		PublishedAt:  time.Date(2022, 2, 2, 3, 0, 0, 0, time.UTC),
+	}
+
+	expectGetJobQuery(dbmock, job)
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Posted 2022/02/01")
+	assert.Contains(t, respBody, `datetime="2022-02-01T21:00:00-06:00"`)
+}
+
+func TestViewJobJSONLD(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos </script><script>alert(1)</script>",
+		Organization: "Org",
+		Description:  sql.NullString{String: "Coolest job eva", Valid: true},
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Email:        "test@example.com",
+		PublishedAt:  time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	expectGetJobQuery(dbmock, job)
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, job.ID), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	matches := regexp.MustCompile(`<script type="application/ld\+json">(.+)</script>`).FindStringSubmatch(respBody)
+	if !assert.Len(t, matches, 2) {
+		return
+	}
+
+	var posting map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(matches[1]), &posting))
+
+	assert.Equal(t, "https://schema.org", posting["@context"])
+	assert.Equal(t, "JobPosting", posting["@type"])
+	assert.Equal(t, job.Position, posting["title"])
+	assert.Equal(t, "2022-02-02", posting["datePosted"])
+	assert.Equal(t, fmt.Sprintf("%s/jobs/1", conf.URL), posting["url"])
+	assert.Contains(t, posting["description"], "Coolest job eva")
+	assert.Equal(t, map[string]interface{}{
+		"@type":  "Organization",
+		"name":   "Org",
+		"sameAs": "https://devict.org",
+	}, posting["hiringOrganization"])
+}
+
+func TestEditJobUnauthorized(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+
+	job := data.Job{ID: "1", PublishedAt: time.Now()}
+
+	expectGetJobQuery(dbmock, job)
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/edit?token=incorrect", s.URL, job.ID), nil)
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+func TestUpdateJobUnauthorized(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+
+	job := data.Job{ID: "1", PublishedAt: time.Now()}
+
+	expectGetJobQuery(dbmock, job)
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s?token=incorrect", s.URL, job.ID), []byte("daaaata"))
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+func TestEditJobAuthorized(t *testing.T) {
+	_, _, dbmock, conf := makeServer(t)
+
+	job := data.Job{
+		Position:     "A position",
+		Organization: "An organization",
+		Description:  sql.NullString{String: "A description", Valid: true},
+		ID:           "1",
+		Email:        "secret@secret.com",
+		PublishedAt:  time.Now(),
+		ApplyCount:   7,
+	}
+
+	// Query executes twice, once for the auth middleware, and
+	// a second time for the actual route
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+
+	signedEditRoute := server.SignedJobRoute(job, conf)
+	respBody, resp := sendRequest(t, signedEditRoute, nil)
+
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Regexp(t, fmt.Sprintf(`<input.+name="position".*value="%s".*>`, job.Position), respBody)
+	assert.Regexp(t, fmt.Sprintf(`<input.+name="organization".*value="%s".*>`, job.Organization), respBody)
+	assert.Regexp(t, fmt.Sprintf(`<textarea.+name="description".*>%s</textarea>`, job.Description.String), respBody)
+	assert.Contains(t, respBody, "Apply clicks so far: 7")
+}
+
+func TestEditJobPreviousSecret(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:          "new-secret",
+		PreviousAppSecrets: []string{"old-secret"},
+		Env:                "debug",
+	})
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "A position", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	tests := map[string]struct {
+		token        string
+		expectStatus int
+	}{
+		"current secret":  {server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), "new-secret"), 200},
+		"previous secret": {server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), "old-secret"), 200},
+		"unknown secret":  {server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), "other-secret"), 403},
+		"bogus":           {"bogus", 403},
+	}
+
+	for name, tt := range tests {
+		expectGetJobQuery(dbmock, job)
+		if tt.expectStatus == 200 {
+			expectGetJobQuery(dbmock, job)
+		}
+
+		_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/edit?token=%s", s.URL, job.ID, url.QueryEscape(tt.token)), nil)
+		assert.Equal(t, tt.expectStatus, resp.StatusCode, name)
+	}
+
+	// New links are signed with the current secret, and without a lifetime
+	// set they last as long as the job
+	assert.Contains(t, server.SignedJobRoute(job, conf), url.QueryEscape(server.ExpiringSignatureForJob(job, job.ExpiresAt(), "new-secret")))
+}
+
+func TestEditJobExpiringToken(t *testing.T) {
+	s, _, dbmock, conf := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", EditLinkLifetime: time.Hour})
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "A position", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	valid := server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)
+	_, sig, _ := strings.Cut(valid, ".")
+
+	tests := map[string]struct {
+		route        string
+		expectStatus int
+	}{
+		"signed route":   {server.SignedJobRoute(job, conf), 200},
+		"valid":          {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(valid)), 200},
+		"expired":        {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(-time.Minute), conf.AppSecret))), 403},
+		"extended":       {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(fmt.Sprintf("%d.%s", time.Now().Add(48*time.Hour).Unix(), sig))), 403},
+		"bad expiry":     {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape("soon."+sig)), 403},
+		"wrong job":      {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(server.ExpiringSignatureForJob(data.Job{ID: "2"}, time.Now().Add(time.Hour), conf.AppSecret))), 403},
+		"without expiry": {fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(server.SignatureForJob(job, conf.AppSecret))), 403},
+	}
+
+	for name, tt := range tests {
+		expectGetJobQuery(dbmock, job)
+		if tt.expectStatus == 200 {
+			expectGetJobQuery(dbmock, job)
+		}
+
+		_, resp := sendRequest(t, tt.route, nil)
+		assert.Equal(t, tt.expectStatus, resp.StatusCode, name)
+	}
+
+	// Tokens without an expiry still work for jobs posted before links
+	// started expiring
+	old := data.Job{ID: "1", Position: "A position", Email: "secret@secret.com", PublishedAt: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)}
+	expectGetJobQuery(dbmock, old)
+	expectGetJobQuery(dbmock, old)
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/1/edit?token=%s", s.URL, url.QueryEscape(server.SignatureForJob(old, conf.AppSecret))), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// Links carry their expiry
+	assert.Regexp(t, `token=\d+\.`, server.SignedJobRoute(job, conf))
+	assert.Regexp(t, `token=\d+\.`, server.SignedDeleteRoute(job, conf))
+	assert.Regexp(t, `token=\d+\.`, server.SignedConfirmRoute(job, conf))
+}
+
+func TestUpdateJobAuthorized(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	tests := []struct {
+		values            map[string][]string
+		expectSuccess     bool
+		expectErrMessages []string
+	}{
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {""},
+				"url":          {"https://devict.org"},
+			},
+			expectSuccess: true,
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {"Super rad place to work"},
+				"url":          {""},
+			},
+			expectSuccess: true,
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {""},
+				"url":          {""},
+			},
+			expectSuccess:     false,
+			expectErrMessages: []string{en(data.ErrNoUrlOrDescription)},
+		},
+		{
+			values: map[string][]string{
+				"position":     {"Pos"},
+				"organization": {"Org"},
+				"description":  {""},
+				"url":          {"invalid"},
+			},
+			expectSuccess:     false,
+			expectErrMessages: []string{en(data.ErrInvalidUrl)},
+		},
+	}
+
+	for _, tt := range tests {
+		job := data.Job{
+			ID:           "1",
+			Position:     "Original Position",
+			Organization: "Original Organization",
+			Url:          sql.NullString{Valid: false},
+			Description:  sql.NullString{String: "Original Description", Valid: true},
+			Email:        "secret@secret.com",
+			PublishedAt:  time.Now(),
+		}
+
+		// Expect requireAuth query
+		expectGetJobQuery(dbmock, job)
+
+		desc := tt.values["description"][0]
+		urlVal := tt.values["url"][0]
+		newParams := data.Job{
+			ID:           job.ID,
+			Position:     tt.values["position"][0],
+			Organization: tt.values["organization"][0],
+			Description:  sql.NullString{String: desc, Valid: desc != ""},
+			Url:          sql.NullString{String: urlVal, Valid: urlVal != ""},
+			PublishedAt:  job.PublishedAt,
+			Email:        job.Email,
+		}
+
+		expectGetJobQuery(dbmock, job)
+		if tt.expectSuccess {
+			dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = .+`).WithArgs(
+				tt.values["position"][0],
+				tt.values["organization"][0],
+				sql.NullString{String: urlVal, Valid: urlVal != ""},
+				sql.NullString{String: desc, Valid: desc != ""},
+				"{}",
+				sql.NullString{},
+				data.Slugify(tt.values["position"][0], tt.values["organization"][0]),
+				job.ID,
+			).WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditUpdate)
+
+			expectSelectJobsQuery(dbmock, []data.Job{newParams})
+		}
+
+		reqBody := url.Values(tt.values).Encode()
+		route := fmt.Sprintf(
+			"%s/jobs/%s?token=%s",
+			s.URL,
+			job.ID,
+			server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret),
+		)
+		respBody, resp := sendRequest(t, route, []byte(reqBody))
+		svcmock.flush()
+
+		// Successes redirect to the index, failures get the form back
+		if tt.expectSuccess {
+			assert.Equal(t, 200, resp.StatusCode)
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		}
+
+		// Should not resend any notifications on updates
+		assert.Empty(t, svcmock.emails)
+		assert.Empty(t, svcmock.tweets)
+		assert.Empty(t, svcmock.slacks)
+
+		if tt.expectSuccess {
+			assert.Contains(t, respBody, tt.values["position"][0])
+			assert.Contains(t, respBody, tt.values["organization"][0])
+		} else {
+			for _, errMsg := range tt.expectErrMessages {
+				assert.Contains(t, respBody, errMsg)
+			}
+
+			// The form is filled in with what was submitted
+			assert.Contains(t, respBody, `name="position" class="form-input mb-3"  value="Pos"`)
+			assert.Contains(t, respBody, `name="organization" class="form-input mb-3" value="Org"`)
+			assert.Contains(t, respBody, fmt.Sprintf(`name="url" class="form-input mb-3" value="%s"`, urlVal))
+			assert.NotContains(t, respBody, "Original Description")
+		}
+	}
+
+}
+
+func TestUpdateJobAudit(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	// sqlmock only reports unexpected calls to the caller, which logs them,
+	// so a second audit row would show up in the log
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	job := data.Job{ID: "1", Position: "Pos", Organization: "Org", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = .+`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditUpdate)
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+
+	values := url.Values{
+		"position":     {"New Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+	}
+	route := fmt.Sprintf("%s/jobs/%s?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
+	_, resp := sendRequest(t, route, []byte(values.Encode()))
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+	assert.NotContains(t, logs.String(), "recordAudit")
+}
+
+func TestConfirmDeleteJob(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "A position",
+		Organization: "An organization",
+		Email:        "secret@secret.com",
+		PublishedAt:  time.Now(),
+	}
+
+	// Once for the auth middleware, once for the route
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+
+	route := fmt.Sprintf(
+		"%s/jobs/%s/delete?token=%s",
+		s.URL,
+		job.ID,
+		url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)),
+	)
+	respBody, resp := sendRequest(t, route, nil)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, job.Position)
+	assert.Regexp(t, fmt.Sprintf(`<form.+action="/jobs/%s/delete\?token=.+">`, job.ID), respBody)
+
+	// A GET must never delete anything
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestDeleteJobUnauthorized(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{ID: "1", PublishedAt: time.Now()}
+
+	expectGetJobQuery(dbmock, job)
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/delete?token=incorrect", s.URL, job.ID), []byte(""))
+	assert.Equal(t, 403, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestDeleteJobAuthorized(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	job := data.Job{ID: "1", Position: "A position", Email: "secret@secret.com", PublishedAt: time.Now()}
+
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = .+`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditDelete)
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	route := fmt.Sprintf(
+		"%s/jobs/%s/delete?token=%s",
+		s.URL,
+		job.ID,
+		url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)),
+	)
+	respBody, resp := sendRequest(t, route, []byte(""))
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Job deleted!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestDeleteJobSlackFollowUp(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServerWithConfig(t, &config.Config{
+		AppSecret:          "sup",
+		Env:                "debug",
+		NotifySlack:        true,
+		SlackExpiryNotices: true,
+	})
+	defer s.Close()
+
+	published := data.Job{
+		ID:          "1",
+		Position:    "A position",
+		Email:       "secret@secret.com",
+		PublishedAt: time.Now(),
+		Confirmed:   true,
+		Status:      data.StatusApproved,
+		SlackTS:     sql.NullString{String: "1234.5678", Valid: true},
+	}
+	unconfirmed := published
+	unconfirmed.ID = "2"
+	unconfirmed.Confirmed = false
+
+	for _, job := range []data.Job{published, unconfirmed} {
+		resetServiceMock(svcmock)
+
+		expectGetJobQuery(dbmock, job)
+		expectGetJobQuery(dbmock, job)
+		dbmock.ExpectExec(`UPDATE jobs SET deleted_at = NOW\(\) WHERE id = .+`).
+			WithArgs(job.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditDelete)
+		expectSelectJobsQuery(dbmock, []data.Job{})
+
+		route := fmt.Sprintf("%s/jobs/%s/delete?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
+		_, resp := sendRequest(t, route, []byte(""))
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+		svcmock.flush()
+
+		if job.Confirmed {
+			// The follow-up is threaded under the announcement
+			if assert.Len(t, svcmock.closed, 1) {
+				assert.Equal(t, "1234.5678", svcmock.closed[0].SlackTS.String)
+			}
+		} else {
+			// Jobs that were never announced aren't followed up on
+			assert.Empty(t, svcmock.closed)
+		}
+	}
+}
+
+func TestInboundEmail(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	timestamp := fmt.Sprint(time.Now().Unix())
+	validSignature := server.SignatureForInboundEmail(timestamp, "tok", conf.InboundEmailKey)
+
+	tests := []struct {
+		values       map[string][]string
+		expectStatus int
+		expectJob    data.Job
+	}{
+		{
+			values: map[string][]string{
+				"sender":     {"hiring@example.com"},
+				"from":       {"Hiring Team <hiring@example.com>"},
+				"subject":    {"Go Developer @ Example Co"},
+				"body-plain": {"Come write Go with us!\n"},
+				"timestamp":  {timestamp},
+				"token":      {"tok"},
+				"signature":  {validSignature},
+			},
+			expectStatus: 200,
+			expectJob: data.Job{
+				Position:     "Go Developer",
+				Organization: "Example Co",
+				Description:  sql.NullString{String: "Come write Go with us!", Valid: true},
+				Email:        "hiring@example.com",
+				Confirmed:    true,
+			},
+		},
+		{
+			values: map[string][]string{
+				"from":       {"Hiring Team <hiring@example.com>"},
+				"subject":    {"Go Developer"},
+				"body-plain": {"Come write Go with us!"},
+				"timestamp":  {timestamp},
+				"token":      {"tok"},
+				"signature":  {validSignature},
+			},
+			expectStatus: 200,
+			expectJob: data.Job{
+				Position:     "Go Developer",
+				Organization: "example.com",
+				Description:  sql.NullString{String: "Come write Go with us!", Valid: true},
+				Email:        "hiring@example.com",
+				Confirmed:    true,
+			},
+		},
+		{
+			values: map[string][]string{
+				"sender":     {"hiring@example.com"},
+				"subject":    {"Go Developer @ Example Co"},
+				"body-plain": {"Come write Go with us!"},
+				"timestamp":  {timestamp},
+				"token":      {"tok"},
+				"signature":  {"forged"},
+			},
+			expectStatus: 403,
+		},
+		{
+			values: map[string][]string{
+				"sender":     {"hiring@example.com"},
+				"subject":    {""},
+				"body-plain": {"Come write Go with us!"},
+				"timestamp":  {timestamp},
+				"token":      {"tok"},
+				"signature":  {validSignature},
+			},
+			expectStatus: 406,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.expectStatus == 200 {
+			dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+				tt.expectJob.Position,
+				tt.expectJob.Organization,
+				sql.NullString{},
+				tt.expectJob.Description,
+				tt.expectJob.Email,
+				false,
+				sql.NullString{},
+				true,
+				"{}",
+				"approved",
+				data.Slugify(tt.expectJob.Position, tt.expectJob.Organization),
+			).WillReturnRows(
+				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(tt.expectJob)...),
+			)
+		}
+
+		reqBody := url.Values(tt.values).Encode()
+		_, resp := sendRequest(t, fmt.Sprintf("%s/integrations/email/inbound", s.URL), []byte(reqBody))
+		svcmock.flush()
+
+		assert.Equal(t, tt.expectStatus, resp.StatusCode)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+
+		if tt.expectStatus == 200 {
+			assert.Equal(t, 1, len(svcmock.emails))
+			assert.Equal(t, tt.expectJob.Email, svcmock.emails[0].recipient)
+			assert.Equal(t, 1, len(svcmock.slacks))
+			assert.Equal(t, 1, len(svcmock.tweets))
+		} else {
+			assert.Empty(t, svcmock.emails)
+			assert.Empty(t, svcmock.slacks)
+			assert.Empty(t, svcmock.tweets)
+		}
+
+		resetServiceMock(svcmock)
+	}
+}
+
+func TestCreateJobLocalized(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	tests := []struct {
+		acceptLanguage string
+		expectLang     string
+		expectErr      string
+		expectButton   string
+	}{
+		{acceptLanguage: "", expectLang: "en", expectErr: "Must provide either a Url or a Description", expectButton: "Post a job"},
+		{acceptLanguage: "es-MX,es;q=0.9,en;q=0.8", expectLang: "es", expectErr: "Debe indicar una URL o una descripción", expectButton: "Publicar un empleo"},
+		{acceptLanguage: "fr", expectLang: "en", expectErr: "Must provide either a Url or a Description", expectButton: "Post a job"},
+	}
+
+	values := url.Values{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"email":        {"test@example.com"},
+	}
+
+	for _, tt := range tests {
+		cookieJar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		assert.NoError(t, err)
+		client := http.Client{Jar: cookieJar}
+
+		req, err := http.NewRequest(http.MethodPost, s.URL+"/jobs", strings.NewReader(values.Encode()))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+
+		resp, err := client.Do(req)
+		if !assert.NoError(t, err, tt.acceptLanguage) {
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, tt.acceptLanguage)
+		assert.Contains(t, string(body), fmt.Sprintf(`<html lang="%s"`, tt.expectLang), tt.acceptLanguage)
+		assert.Contains(t, string(body), html.EscapeString(tt.expectErr), tt.acceptLanguage)
+		assert.Contains(t, string(body), tt.expectButton, tt.acceptLanguage)
+	}
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestIndexVariesByLanguage(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	jobs := []data.Job{{ID: "1", Position: "Pos", PublishedAt: time.Date(2022, 2, 2, 0, 0, 0, 0, time.UTC)}}
+
+	etags := map[string]bool{}
+	for _, lang := range []string{"en", "es"} {
+		expectSelectJobsQuery(dbmock, jobs)
+
+		req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Accept-Language", lang)
+
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if lang == "es" {
+			assert.Contains(t, string(body), "Publicado el 2022/02/02")
+			assert.Regexp(t, `Publicado hace \d+ días`, string(body))
+		} else {
+			assert.Contains(t, string(body), "Posted 2022/02/02")
+			assert.Regexp(t, `Posted \d+ days ago`, string(body))
+		}
+		assert.Equal(t, "Accept-Language", resp.Header.Get("Vary"))
+		etags[resp.Header.Get("ETag")] = true
+	}
+
+	// A cached page in one language isn't reused for the other
+	assert.Len(t, etags, 2)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
+
+func TestCreateJobRetriesEmail(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	svcmock.emailFailures = 2
 
-	body, _ := sendRequest(t, s.URL, nil)
+	values := url.Values{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+		"email":        {"test@example.com"},
+	}
 
-	assert.Contains(t, string(body), "Pos 1")
-	assert.Contains(t, string(body), "Pos 2")
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnRows(
+		sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{Confirmed: true})...),
+	)
+	expectSelectJobsQuery(dbmock, []data.Job{})
 
-	// TODO: What other assertions do we want to make about the home page?
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Job created!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Delivered on the third try
+	assert.Equal(t, 0, svcmock.emailFailures)
+	if assert.Len(t, svcmock.emails, 1) {
+		assert.Equal(t, "Job Created!", svcmock.emails[0].subject)
+	}
 }
 
-func TestNewJob(t *testing.T) {
+func TestCreateJobKeepsValues(t *testing.T) {
 	s, _, _, _ := makeServer(t)
 	defer s.Close()
 
-	body, resp := sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+	// Long descriptions are kept too, which wouldn't fit in the session
+	// cookie
+	description := strings.Repeat("Lots to say about this job. ", 300)
 
-	assert.Equal(t, 200, resp.StatusCode)
+	values := url.Values{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+		"description":  {description},
+		"email":        {"not an email"},
+	}
+	body, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, "/jobs", resp.Request.URL.Path)
+	assert.Contains(t, body, en(data.ErrInvalidEmail))
+
+	assert.Contains(t, body, `name="position" class="form-input mb-3"  value="Pos"`)
+	assert.Contains(t, body, `name="organization" class="form-input mb-3" value="Org"`)
+	assert.Contains(t, body, `value="https://devict.org"`)
+	assert.Contains(t, body, `value="not an email"`)
+	assert.Contains(t, body, description)
+}
 
-	// - assert that all the right fields are present
-	tests := []struct {
-		field    string
-		required bool
-		textArea bool
-	}{
-		{"position", true, false},
-		{"organization", true, false},
-		{"description", false, true},
-		{"url", false, false},
-		{"email", true, false},
+func TestCreateJobTooLong(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{AppSecret: "sup", Env: "debug", MaxPositionLength: 120})
+	defer s.Close()
+
+	body, _ := sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+	assert.Contains(t, body, `maxlength="120"`)
+
+	values := url.Values{
+		"position":     {strings.Repeat("a", 121)},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+		"email":        {"test@example.com"},
 	}
+	body, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(t, body, en(data.ErrPositionTooLong))
 
-	for _, tt := range tests {
-		reqStr := ""
-		if tt.required {
-			reqStr = "required.*"
-		}
+	// Nothing was saved
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
 
-		base := "input"
-		if tt.textArea {
-			base = "textarea"
-		}
+func TestCreateJobRateLimit(t *testing.T) {
+	s, _, _, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:            "sup",
+		Env:                  "debug",
+		SubmissionsPerMinute: 2,
+	})
+	defer s.Close()
 
-		r := fmt.Sprintf(`<%s.+name="%s".*%s>`, base, tt.field, reqStr)
-		assert.Regexp(t, regexp.MustCompile(r), body)
+	// Invalid submissions still count against the limit
+	reqBody := url.Values(map[string][]string{"position": {"Pos"}}).Encode()
+
+	for i := 0; i < 2; i++ {
+		_, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+
+	// Other routes aren't limited
+	_, resp = sendRequest(t, fmt.Sprintf("%s/new", s.URL), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestCreateJobRateLimitGrace(t *testing.T) {
+	s, _, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:            "sup",
+		Env:                  "debug",
+		SubmissionsPerMinute: 1,
+		RateLimitGrace:       true,
+		AllowedMetadataKeys:  []string{"visa_sponsorship"},
+	})
+	defer s.Close()
+
+	// Use up the limit with an invalid submission
+	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(url.Values{"position": {"Pos"}}.Encode()))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	values := url.Values{
+		"position":                   {"Pos"},
+		"organization":               {"Org"},
+		"url":                        {"https://devict.org"},
+		"description":                {strings.Repeat("Coolest job eva. ", 300)},
+		"email":                      {"test@example.com"},
+		"metadata[visa_sponsorship]": {"Available"},
 	}
+	body, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+
+	// The form comes back with the 429 rather than a bare one
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.Contains(t, body, "please wait 60 seconds")
+
+	assert.Contains(t, body, `name="position" class="form-input mb-3"  value="Pos"`)
+	assert.Contains(t, body, `value="Org"`)
+	assert.Contains(t, body, `value="https://devict.org"`)
+	assert.Contains(t, body, ">"+values.Get("description")+"</textarea>")
+	assert.Contains(t, body, `value="test@example.com"`)
+	assert.Contains(t, body, `name="metadata[visa_sponsorship]" class="form-input mb-3" value="Available"`)
+
+	// Nothing was saved
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Invalid submissions still get a plain 429
+	body, resp = sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(url.Values{"position": {"Pos"}}.Encode()))
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotContains(t, body, "please wait")
+
+	// So do ones that can't be parsed
+	body, resp = sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte("position=%zz"))
+	assert.Equal(t, 429, resp.StatusCode)
+	assert.NotContains(t, body, "please wait")
 }
 
-func TestCreateJob(t *testing.T) {
+func TestCreateJobCaptcha(t *testing.T) {
 	s, svcmock, dbmock, conf := makeServer(t)
 	defer s.Close()
 
+	conf.CaptchaSiteKey = "site-key"
+	svcmock.captchaToken = "valid"
+
 	tests := []struct {
-		values            map[string][]string
-		expectSuccess     bool
-		expectErrMessages []string
+		token         []string
+		expectSuccess bool
 	}{
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {""},
-				"url":          {"https://devict.org"},
-				"email":        {"test@example.com"},
-			},
-			expectSuccess: true,
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {"Super rad place to work"},
-				"url":          {""},
-				"email":        {"test@example.com"},
-			},
-			expectSuccess: true,
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {""},
-				"url":          {""},
-				"email":        {"test@example.com"},
-			},
-			expectSuccess:     false,
-			expectErrMessages: []string{data.ErrNoUrlOrDescription},
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {"Cool cool cool"},
-				"url":          {""},
-				"email":        {""},
-			},
-			expectSuccess:     false,
-			expectErrMessages: []string{data.ErrNoEmail},
-		},
+		{token: nil, expectSuccess: false},
+		{token: []string{"forged"}, expectSuccess: false},
+		{token: []string{"valid"}, expectSuccess: true},
 	}
 
 	for _, tt := range tests {
-		newJob := data.Job{
-			ID:           "1",
-			Position:     tt.values["position"][0],
-			Organization: tt.values["organization"][0],
-			Description:  sql.NullString{String: tt.values["description"][0], Valid: true},
-			Url:          sql.NullString{String: tt.values["url"][0], Valid: true},
-			Email:        tt.values["email"][0],
-			PublishedAt:  time.Now(),
+		values := map[string][]string{
+			"position":     {"Pos"},
+			"organization": {"Org"},
+			"description":  {"Cool cool cool"},
+			"url":          {""},
+			"email":        {"test@example.com"},
+		}
+		if tt.token != nil {
+			values["g-recaptcha-response"] = tt.token
 		}
 
 		if tt.expectSuccess {
 			dbmock.ExpectQuery(`INSERT INTO jobs`).WillReturnRows(
-				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(newJob)...),
+				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{Confirmed: true})...),
 			)
-
-			expectSelectJobsQuery(dbmock, []data.Job{newJob})
+			expectSelectJobsQuery(dbmock, []data.Job{})
 		}
 
-		reqBody := url.Values(tt.values).Encode()
+		reqBody := url.Values(values).Encode()
 		respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+		svcmock.flush()
 
-		// Should follow the redirect and result in a 200 regardless of success/failure
-		assert.Equal(t, 200, resp.StatusCode)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
 
 		if tt.expectSuccess {
-			assert.Contains(t, respBody, tt.values["position"][0])
-			assert.Contains(t, respBody, tt.values["organization"][0])
-
-			assert.Equal(t, 1, len(svcmock.emails))
-			assert.Equal(t, 1, len(svcmock.tweets))
-			assert.Equal(t, 1, len(svcmock.slacks))
-
-			assert.Equal(t, "Job Created!", svcmock.emails[0].subject)
-			assert.Equal(t, tt.values["email"][0], svcmock.emails[0].recipient)
-			assert.Contains(t, svcmock.emails[0].body, server.SignedJobRoute(newJob, conf))
-
-			assert.Contains(t, svcmock.tweets, newJob)
-			assert.Contains(t, svcmock.slacks, newJob)
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Contains(t, respBody, "Job created!")
 		} else {
-			for _, errMsg := range tt.expectErrMessages {
-				assert.Contains(t, respBody, errMsg)
-			}
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+			assert.Contains(t, respBody, en(data.ErrCaptchaFailed))
+			assert.Contains(t, respBody, `<div class="h-captcha" data-sitekey="site-key"></div>`)
 			assert.Empty(t, svcmock.emails)
-			assert.Empty(t, svcmock.tweets)
-			assert.Empty(t, svcmock.slacks)
 		}
 
 		resetServiceMock(svcmock)
 	}
 }
 
-func TestViewJob(t *testing.T) {
-	s, _, dbmock, _ := makeServer(t)
+func TestCreateJobRequiresConfirmation(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.RequireConfirmation = true
+
+	values := map[string][]string{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"description":  {"Cool cool cool"},
+		"url":          {""},
+		"email":        {"test@example.com"},
+	}
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Description:  sql.NullString{String: "Cool cool cool", Valid: true},
+		Email:        "test@example.com",
+		PublishedAt:  time.Now(),
+	}
+
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+		"Pos",
+		"Org",
+		sql.NullString{},
+		sql.NullString{String: "Cool cool cool", Valid: true},
+		"test@example.com",
+		false,
+		sql.NullString{},
+		false,
+		"{}",
+		"approved",
+		"pos-org",
+	).WillReturnRows(
+		sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(job)...),
+	)
+	// The unconfirmed job isn't listed
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	reqBody := url.Values(values).Encode()
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "confirm")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// Only the confirmation email goes out, nothing is announced yet
+	if assert.Equal(t, 1, len(svcmock.emails)) {
+		assert.Equal(t, "Confirm your job posting", svcmock.emails[0].subject)
+		assert.Contains(t, svcmock.emails[0].body, server.SignedConfirmRoute(job, conf))
+	}
+	assert.Empty(t, svcmock.slacks)
+	assert.Empty(t, svcmock.tweets)
+
+	resetServiceMock(svcmock)
+
+	// Once for the auth middleware, once for the route
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs SET confirmed = true WHERE id = .+`).
+		WithArgs(job.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Then the redirect to the job
+	confirmed := job
+	confirmed.Confirmed = true
+	expectGetJobQuery(dbmock, confirmed)
+
+	respBody, resp = sendRequest(t, server.SignedConfirmRoute(job, conf), nil)
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Job confirmed!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	if assert.Equal(t, 1, len(svcmock.emails)) {
+		assert.Equal(t, "Job Created!", svcmock.emails[0].subject)
+	}
+	assert.Equal(t, 1, len(svcmock.slacks))
+	assert.Equal(t, 1, len(svcmock.tweets))
+
+	resetServiceMock(svcmock)
+
+	// Confirming twice doesn't announce the job again
+	expectGetJobQuery(dbmock, confirmed)
+	expectGetJobQuery(dbmock, confirmed)
+	expectGetJobQuery(dbmock, confirmed)
+
+	respBody, _ = sendRequest(t, server.SignedConfirmRoute(job, conf), nil)
+	svcmock.flush()
+
+	assert.Contains(t, respBody, "Job already confirmed!")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+	assert.Empty(t, svcmock.emails)
+	assert.Empty(t, svcmock.slacks)
+}
+
+func TestCreateJobRequiresApproval(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	conf.RequireApproval = true
+
+	values := url.Values{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+		"email":        {"test@example.com"},
+	}
+
+	job := data.Job{ID: "1", Position: "Pos", Confirmed: true, Status: data.StatusPending}
+
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+		"Pos",
+		"Org",
+		sql.NullString{String: "https://devict.org", Valid: true},
+		sql.NullString{},
+		"test@example.com",
+		false,
+		sql.NullString{},
+		true,
+		"{}",
+		data.StatusPending,
+		"pos-org",
+	).WillReturnRows(
+		sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(job)...),
+	)
+	// The pending job isn't listed
+	expectSelectJobsQuery(dbmock, []data.Job{})
+
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(values.Encode()))
+	svcmock.flush()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "It will be published once it has been reviewed.")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// The poster gets their edit links, but nothing is announced yet
+	assert.Equal(t, 1, len(svcmock.emails))
+	assert.Empty(t, svcmock.slacks)
+	assert.Empty(t, svcmock.tweets)
+}
+
+func TestModerateJob(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:       "sup",
+		Env:             "debug",
+		AdminUser:       "admin",
+		AdminPassword:   "hunter2",
+		RequireApproval: true,
+		NotifySlack:     true,
+	})
 	defer s.Close()
 
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
 	tests := []struct {
-		job data.Job
+		action         string
+		from           string
+		to             string
+		expectFlash    string
+		expectSubject  string
+		expectAnnounce bool
 	}{
-		{
-			job: data.Job{
-				ID:           "1",
-				Position:     "Pos",
-				Organization: "Org",
-				Description:  sql.NullString{String: "Coolest job eva", Valid: true},
-				Url:          sql.NullString{},
-				Email:        "test@example.com",
-			},
-		},
-		{
-			job: data.Job{
-				ID:           "2",
-				Position:     "Pos 2",
-				Organization: "Org 2",
-				Description:  sql.NullString{},
-				Url:          sql.NullString{String: "https://devict.org", Valid: true},
-				Email:        "test@example.com",
-			},
-		},
+		{action: "approve", from: data.StatusPending, to: data.StatusApproved, expectFlash: "Job approved!", expectSubject: "Job Approved!", expectAnnounce: true},
+		{action: "reject", from: data.StatusPending, to: data.StatusRejected, expectFlash: "Job rejected!", expectSubject: "Job Rejected"},
+		{action: "reject", from: data.StatusApproved, to: data.StatusRejected, expectFlash: "Job rejected!", expectSubject: "Job Rejected"},
+		// Approved again after a change of heart, but not announced twice
+		{action: "approve", from: data.StatusRejected, to: data.StatusApproved, expectFlash: "Job approved!", expectSubject: "Job Approved!"},
+		{action: "approve", from: data.StatusApproved, expectFlash: "Job already approved!"},
+		{action: "reject", from: data.StatusRejected, expectFlash: "Job already rejected!"},
 	}
 
 	for _, tt := range tests {
-		expectGetJobQuery(dbmock, tt.job)
+		resetServiceMock(svcmock)
+		job := data.Job{ID: "1", Position: "Pos", Email: "test@example.com", Confirmed: true, Status: tt.from}
 
-		respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s", s.URL, tt.job.ID), nil)
+		expectGetJobQuery(dbmock, job)
+		if tt.to != "" {
+			dbmock.ExpectExec(`UPDATE jobs SET status = \$1 WHERE id = \$2 AND status = \$3`).
+				WithArgs(tt.to, job.ID, tt.from).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAuditRecord(dbmock, job.ID, "admin", tt.action)
+		}
+		expectAdminQueries(dbmock, nil)
+
+		body, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/%s", adminURL, job.ID, tt.action), []byte(""))
+		svcmock.flush()
 
+		assert.Equal(t, 200, resp.StatusCode, tt)
+		assert.Contains(t, body, tt.expectFlash, tt)
+		assert.NoError(t, dbmock.ExpectationsWereMet(), tt)
+
+		if tt.expectSubject == "" {
+			assert.Empty(t, svcmock.emails, tt)
+		} else if assert.Equal(t, 1, len(svcmock.emails), tt) {
+			assert.Equal(t, "test@example.com", svcmock.emails[0].recipient, tt)
+			assert.Equal(t, tt.expectSubject, svcmock.emails[0].subject, tt)
+		}
+
+		if tt.expectAnnounce {
+			assert.Equal(t, 1, len(svcmock.slacks), tt)
+		} else {
+			assert.Empty(t, svcmock.slacks, tt)
+		}
+	}
+}
+
+func TestModerateJobEmails(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:       "sup",
+		Env:             "debug",
+		AdminUser:       "admin",
+		AdminPassword:   "hunter2",
+		RequireApproval: true,
+	})
+	defer s.Close()
+
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
+	moderate := func(job data.Job, action, to string) email {
+		resetServiceMock(svcmock)
+
+		expectGetJobQuery(dbmock, job)
+		dbmock.ExpectExec(`UPDATE jobs SET status = \$1 WHERE id = \$2 AND status = \$3`).
+			WithArgs(to, job.ID, job.Status).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditRecord(dbmock, job.ID, "admin", action)
+		expectAdminQueries(dbmock, nil)
+
+		_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/%s", adminURL, job.ID, action), []byte(""))
+		svcmock.flush()
 		assert.Equal(t, 200, resp.StatusCode)
-		assert.Contains(t, respBody, tt.job.Position)
-		assert.Contains(t, respBody, tt.job.Organization)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
 
-		if tt.job.Description.Valid {
-			assert.Contains(t, respBody, tt.job.Description.String)
+		if !assert.Len(t, svcmock.emails, 1) {
+			return email{}
 		}
+		return svcmock.emails[0]
+	}
+
+	// Jobs approved before they're confirmed aren't live yet
+	job := data.Job{ID: "1", Position: "Pos", Email: "test@example.com", Status: data.StatusPending, PublishedAt: time.Now()}
+	sent := moderate(job, "approve", data.StatusApproved)
+	assert.NotContains(t, sent.body, "now live")
+	assert.Contains(t, sent.body, "once you confirm it")
+	assert.Contains(t, sent.body, "/jobs/1/confirm?token=")
+
+	// Positions are escaped in the rejection
+	job = data.Job{ID: "1", Position: "<b>Pos</b>", Email: "test@example.com", Confirmed: true, Status: data.StatusPending}
+	sent = moderate(job, "reject", data.StatusRejected)
+	assert.Contains(t, sent.body, "&lt;b&gt;Pos&lt;/b&gt;")
+}
+
+func TestModerateHeldJob(t *testing.T) {
+	s, svcmock, dbmock, _ := makeServerWithConfig(t, &config.Config{
+		AppSecret:     "sup",
+		Env:           "debug",
+		AdminUser:     "admin",
+		AdminPassword: "hunter2",
+		NotifySlack:   true,
+	})
+	defer s.Close()
+
+	adminURL := strings.Replace(s.URL, "http://", "http://admin:hunter2@", 1) + "/admin"
+
+	tests := []struct {
+		action         string
+		to             string
+		expectFlash    string
+		expectAnnounce bool
+	}{
+		// Held jobs are already approved, but still need approving to be shown
+		{action: "approve", to: data.StatusApproved, expectFlash: "Job approved!", expectAnnounce: true},
+		{action: "reject", to: data.StatusRejected, expectFlash: "Job rejected!"},
+	}
+
+	for _, tt := range tests {
+		resetServiceMock(svcmock)
+		job := data.Job{ID: "1", Position: "Pos", Email: "test@example.com", Confirmed: true, NeedsReview: true, Status: data.StatusApproved}
 
-		if tt.job.Url.Valid {
-			assert.Contains(t, respBody, tt.job.Url.String)
+		expectGetJobQuery(dbmock, job)
+		dbmock.ExpectExec(`UPDATE jobs SET status = \$1, needs_review = FALSE WHERE id = \$2 AND needs_review`).
+			WithArgs(tt.to, job.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuditRecord(dbmock, job.ID, "admin", tt.action)
+		expectAdminQueries(dbmock, nil)
+
+		body, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/%s", adminURL, job.ID, tt.action), []byte(""))
+		svcmock.flush()
+
+		assert.Equal(t, 200, resp.StatusCode, tt)
+		assert.Contains(t, body, tt.expectFlash, tt)
+		assert.NoError(t, dbmock.ExpectationsWereMet(), tt)
+		assert.Equal(t, 1, len(svcmock.emails), tt)
+		if tt.expectAnnounce {
+			assert.Equal(t, 1, len(svcmock.slacks), tt)
+		} else {
+			assert.Empty(t, svcmock.slacks, tt)
 		}
-
-		assert.NotContains(t, respBody, tt.job.Email) // Don't expose the email!
 	}
+
+	// They're listed in the queue
+	expectAdminQueriesWithPending(dbmock, []data.Job{{ID: "2", Position: "Held Pos", NeedsReview: true, Status: data.StatusApproved}}, nil)
+
+	body, _ := sendRequest(t, adminURL, nil)
+	assert.Contains(t, body, "Held Pos")
+	assert.Contains(t, body, "Held for review")
+	assert.Contains(t, body, `action="/admin/jobs/2/approve"`)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
 }
 
-func TestEditJobUnauthorized(t *testing.T) {
-	s, _, dbmock, _ := makeServer(t)
+func TestCreateJobNearDuplicate(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
 
-	job := data.Job{ID: "1", PublishedAt: time.Now()}
+	conf.DuplicateSimilarityThreshold = 0.9
 
-	expectGetJobQuery(dbmock, job)
+	existing := "We are hiring a senior Go developer to build our job board platform. " +
+		"You will work closely with the community team, write tests, review code, " +
+		"and help run our Postgres database in production."
+	tweaked := strings.Replace(existing, "senior", "junior", 1)
 
-	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s/edit?token=incorrect", s.URL, job.ID), nil)
-	assert.Equal(t, 403, resp.StatusCode)
-}
+	values := map[string][]string{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"description":  {tweaked},
+		"url":          {""},
+		"email":        {"test@example.com"},
+	}
 
-func TestUpdateJobUnauthorized(t *testing.T) {
-	s, _, dbmock, _ := makeServer(t)
+	flagged := data.Job{
+		ID:           "2",
+		Position:     "Pos",
+		Organization: "Org",
+		Description:  sql.NullString{String: tweaked, Valid: true},
+		Email:        "test@example.com",
+		NeedsReview:  true,
+		Confirmed:    true,
+	}
 
-	job := data.Job{ID: "1", PublishedAt: time.Now()}
+	// Only jobs that are still up are compared
+	dbmock.ExpectQuery(`SELECT description FROM jobs WHERE description IS NOT NULL AND deleted_at IS NULL AND published_at > \$1`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"description"}).AddRow(existing))
+	dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+		"Pos",
+		"Org",
+		sql.NullString{},
+		sql.NullString{String: tweaked, Valid: true},
+		"test@example.com",
+		true,
+		sql.NullString{},
+		true,
+		"{}",
+		"approved",
+		"pos-org",
+	).WillReturnRows(
+		sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(flagged)...),
+	)
+	expectSelectJobsQuery(dbmock, []data.Job{})
 
-	expectGetJobQuery(dbmock, job)
+	reqBody := url.Values(values).Encode()
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+	svcmock.flush()
 
-	_, resp := sendRequest(t, fmt.Sprintf("%s/jobs/%s?token=incorrect", s.URL, job.ID), []byte("daaaata"))
-	assert.Equal(t, 403, resp.StatusCode)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "reviewed")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+
+	// The poster still gets their links, but nothing is announced
+	assert.Equal(t, 1, len(svcmock.emails))
+	assert.Empty(t, svcmock.slacks)
+	assert.Empty(t, svcmock.tweets)
+	assert.Empty(t, svcmock.discords)
 }
 
-func TestEditJobAuthorized(t *testing.T) {
-	_, _, dbmock, conf := makeServer(t)
+func TestCreateJobRecentDuplicate(t *testing.T) {
+	s, svcmock, dbmock, conf := makeServer(t)
+	defer s.Close()
 
-	job := data.Job{
-		Position:     "A position",
-		Organization: "An organization",
-		Description:  sql.NullString{String: "A description", Valid: true},
-		ID:           "1",
-		Email:        "secret@secret.com",
-		PublishedAt:  time.Now(),
+	conf.DuplicateWindow = time.Hour
+
+	existing := data.Job{
+		ID:           "7",
+		Position:     "Pos",
+		Organization: "Org",
+		Email:        "test@example.com",
+		Confirmed:    true,
 	}
 
-	// Query executes twice, once for the auth middleware, and
-	// a second time for the actual route
-	expectGetJobQuery(dbmock, job)
-	expectGetJobQuery(dbmock, job)
+	values := map[string][]string{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"url":          {"https://devict.org"},
+		"email":        {"test@example.com"},
+	}
 
-	signedEditRoute := server.SignedJobRoute(job, conf)
-	respBody, resp := sendRequest(t, signedEditRoute, nil)
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE lower\(organization\)`).
+		WithArgs("Org", "Pos", "test@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(existing)...))
+	expectGetJobQuery(dbmock, existing)
+
+	reqBody := url.Values(values).Encode()
+	respBody, resp := sendRequest(t, fmt.Sprintf("%s/jobs", s.URL), []byte(reqBody))
+	svcmock.flush()
 
 	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/jobs/7", resp.Request.URL.Path)
+	assert.Contains(t, respBody, "already posted")
+	assert.NoError(t, dbmock.ExpectationsWereMet())
 
-	assert.Regexp(t, fmt.Sprintf(`<input.+name="position".*value="%s".*>`, job.Position), respBody)
-	assert.Regexp(t, fmt.Sprintf(`<input.+name="organization".*value="%s".*>`, job.Organization), respBody)
-	assert.Regexp(t, fmt.Sprintf(`<textarea.+name="description".*>%s</textarea>`, job.Description.String), respBody)
+	// Nothing was inserted or announced
+	assert.Empty(t, svcmock.emails)
+	assert.Empty(t, svcmock.slacks)
 }
 
-func TestUpdateJobAuthorized(t *testing.T) {
-	s, svcmock, dbmock, conf := makeServer(t)
+func TestCreateJobLogo(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
 	defer s.Close()
 
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+	svg := []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+
+	values := map[string][]string{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"description":  {"Cool cool cool"},
+		"url":          {""},
+		"email":        {"test@example.com"},
+	}
+
 	tests := []struct {
-		values            map[string][]string
-		expectSuccess     bool
-		expectErrMessages []string
+		fileName      string
+		content       []byte
+		expectSuccess bool
+		expectErr     string
 	}{
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {""},
-				"url":          {"https://devict.org"},
-			},
-			expectSuccess: true,
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {"Super rad place to work"},
-				"url":          {""},
-			},
-			expectSuccess: true,
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {""},
-				"url":          {""},
-			},
-			expectSuccess:     false,
-			expectErrMessages: []string{data.ErrNoUrlOrDescription},
-		},
-		{
-			values: map[string][]string{
-				"position":     {"Pos"},
-				"organization": {"Org"},
-				"description":  {""},
-				"url":          {"invalid"},
-			},
-			expectSuccess:     false,
-			expectErrMessages: []string{data.ErrInvalidUrl},
-		},
+		{fileName: "logo.png", content: png, expectSuccess: true},
+		{fileName: "logo.svg", content: svg, expectSuccess: true},
+		{fileName: "logo.png", content: append(png, bytes.Repeat([]byte{0}, 1<<20)...), expectErr: en(data.ErrLogoTooLarge)},
+		{fileName: "logo.png", content: []byte("definitely not an image"), expectErr: en(data.ErrInvalidLogo)},
+		{fileName: "logo.gif", content: []byte("GIF89a"), expectErr: en(data.ErrInvalidLogo)},
 	}
 
 	for _, tt := range tests {
-		job := data.Job{
-			ID:           "1",
-			Position:     "Original Position",
-			Organization: "Original Organization",
-			Url:          sql.NullString{Valid: false},
-			Description:  sql.NullString{String: "Original Description", Valid: true},
-			Email:        "secret@secret.com",
-			PublishedAt:  time.Now(),
+		var logoUrl string
+		if tt.expectSuccess {
+			dbmock.ExpectQuery(`INSERT INTO jobs`).WithArgs(
+				"Pos",
+				"Org",
+				sql.NullString{},
+				sql.NullString{String: "Cool cool cool", Valid: true},
+				"test@example.com",
+				false,
+				captureArg{&logoUrl},
+				true,
+				"{}",
+				"approved",
+				"pos-org",
+			).WillReturnRows(
+				sqlmock.NewRows(getDbFields(data.Job{})).AddRow(mockJobRow(data.Job{Confirmed: true})...),
+			)
+			expectSelectJobsQuery(dbmock, []data.Job{})
 		}
 
-		// Expect requireAuth query
-		expectGetJobQuery(dbmock, job)
+		respBody, resp := sendMultipartRequest(t, fmt.Sprintf("%s/jobs", s.URL), values, tt.fileName, tt.content)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
 
-		desc := tt.values["description"][0]
-		urlVal := tt.values["url"][0]
-		newParams := data.Job{
-			ID:           job.ID,
-			Position:     tt.values["position"][0],
-			Organization: tt.values["organization"][0],
-			Description:  sql.NullString{String: desc, Valid: desc != ""},
-			Url:          sql.NullString{String: urlVal, Valid: urlVal != ""},
-			PublishedAt:  job.PublishedAt,
-			Email:        job.Email,
+		if tt.expectSuccess {
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Contains(t, respBody, "Job created!")
+			assert.Regexp(t, `^/uploads/[0-9a-f]{32}\.(png|svg)$`, logoUrl)
+
+			logoBody, logoResp := sendRequest(t, s.URL+logoUrl, nil)
+			assert.Equal(t, 200, logoResp.StatusCode)
+			assert.Equal(t, string(tt.content), logoBody)
+			assert.Contains(t, logoResp.Header.Get("Content-Security-Policy"), "default-src 'none'")
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+			assert.Contains(t, respBody, tt.expectErr)
 		}
+	}
+}
 
-		if tt.expectSuccess {
-			expectGetJobQuery(dbmock, job)
+func TestUpdateJobLogo(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
 
-			dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = .+`).WithArgs(
-				tt.values["position"][0],
-				tt.values["organization"][0],
-				sql.NullString{String: urlVal, Valid: urlVal != ""},
-				sql.NullString{String: desc, Valid: desc != ""},
-				job.ID,
-			).WillReturnResult(sqlmock.NewResult(0, 1))
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
 
-			expectSelectJobsQuery(dbmock, []data.Job{newParams})
-		} else {
-			// On failure, expect twice again for the redirect to /edit
-			// which calls requireAuth, and then getJob for the view
-			expectGetJobQuery(dbmock, job)
-			expectGetJobQuery(dbmock, job)
-		}
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Email:        "secret@secret.com",
+		PublishedAt:  time.Now(),
+		LogoUrl:      sql.NullString{String: "/uploads/old.png", Valid: true},
+	}
+	values := map[string][]string{
+		"position":     {"Pos"},
+		"organization": {"Org"},
+		"description":  {""},
+		"url":          {"https://devict.org"},
+	}
+	route := fmt.Sprintf("%s/jobs/%s?token=%s", s.URL, job.ID, url.QueryEscape(server.ExpiringSignatureForJob(job, time.Now().Add(time.Hour), conf.AppSecret)))
 
-		reqBody := url.Values(tt.values).Encode()
-		route := fmt.Sprintf(
-			"%s/jobs/%s?token=%s",
-			s.URL,
-			job.ID,
-			server.SignatureForJob(job, conf.AppSecret),
-		)
-		respBody, resp := sendRequest(t, route, []byte(reqBody))
+	// A new logo replaces the old one
+	var logoUrl string
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs .+ logo_url = \$6, slug = \$7 WHERE id = \$8`).WithArgs(
+		"Pos",
+		"Org",
+		job.Url,
+		sql.NullString{},
+		"{}",
+		captureArg{&logoUrl},
+		"pos-org",
+		job.ID,
+	).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditUpdate)
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+
+	respBody, resp := sendMultipartRequest(t, route, values, "logo.png", png)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, respBody, "Job updated!")
+	assert.Regexp(t, `^/uploads/[0-9a-f]{32}\.png$`, logoUrl)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
 
-		// Should follow the redirect and result in a 200 regardless of success/failure
-		assert.Equal(t, 200, resp.StatusCode)
+	// Without an upload the old logo is kept
+	expectGetJobQuery(dbmock, job)
+	expectGetJobQuery(dbmock, job)
+	dbmock.ExpectExec(`UPDATE jobs .+ WHERE id = .+`).WithArgs(
+		"Pos",
+		"Org",
+		job.Url,
+		sql.NullString{},
+		"{}",
+		job.LogoUrl,
+		"pos-org",
+		job.ID,
+	).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditRecord(dbmock, job.ID, data.ActorPoster, data.AuditUpdate)
+	expectSelectJobsQuery(dbmock, []data.Job{job})
+
+	_, resp = sendMultipartRequest(t, route, values, "", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, dbmock.ExpectationsWereMet())
 
-		// Should not resend any notifications on updates
-		assert.Empty(t, svcmock.emails)
-		assert.Empty(t, svcmock.tweets)
-		assert.Empty(t, svcmock.slacks)
+	// Bad uploads are sent back to the form
+	for _, content := range [][]byte{append(png, bytes.Repeat([]byte{0}, 1<<20)...), []byte("definitely not an image")} {
+		expectGetJobQuery(dbmock, job)
+		expectGetJobQuery(dbmock, job)
 
-		if tt.expectSuccess {
-			assert.Contains(t, respBody, tt.values["position"][0])
-			assert.Contains(t, respBody, tt.values["organization"][0])
-		} else {
-			for _, errMsg := range tt.expectErrMessages {
-				assert.Contains(t, respBody, errMsg)
-			}
-		}
+		respBody, resp = sendMultipartRequest(t, route, values, "logo.png", content)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Regexp(t, en(data.ErrLogoTooLarge)+"|"+en(data.ErrInvalidLogo), respBody)
+		assert.Contains(t, respBody, `<img src="/uploads/old.png" alt="Current logo"`)
+		assert.NoError(t, dbmock.ExpectationsWereMet())
 	}
+}
 
+func TestIndexLogos(t *testing.T) {
+	s, _, dbmock, _ := makeServer(t)
+	defer s.Close()
+
+	expectSelectJobsQuery(dbmock, []data.Job{
+		{ID: "1", Organization: "Has Logo", LogoUrl: sql.NullString{String: "/uploads/logo.png", Valid: true}},
+		{ID: "2", Organization: "zero logos"},
+	})
+
+	body, _ := sendRequest(t, s.URL, nil)
+	assert.Contains(t, body, `<img src="/uploads/logo.png" alt="Has Logo logo"`)
+	assert.Regexp(t, `class="logo-fallback[^"]*" aria-hidden="true">Z</span>`, body)
+	assert.Equal(t, 1, strings.Count(body, "logo-fallback"))
 }
 
 // Helpers ------------------------------
@@ -414,41 +3162,107 @@ type email struct {
 }
 
 type mockService struct {
-	emails []email
-	tweets []data.Job
-	slacks []data.Job
+	emails   []email
+	tweets   []data.Job
+	slacks   []data.Job
+	closed   []data.Job
+	discords []data.Job
+	toots    []data.Job
+	webhooks []data.Job
+
+	// when set, VerifyCaptcha only accepts this token
+	captchaToken string
+	// SendEmail fails this many times before succeeding
+	emailFailures int
+
+	// flush waits for queued notifications to be sent to the mock
+	flush func()
 }
 
 func (svc *mockService) SendEmail(recipient, subject, body string) error {
+	if svc.emailFailures > 0 {
+		svc.emailFailures--
+		return fmt.Errorf("smtp server unavailable")
+	}
 	svc.emails = append(svc.emails, email{recipient, subject, body})
 	return nil
 }
 
+func (svc *mockService) VerifyCaptcha(token, remoteIP string) error {
+	if svc.captchaToken != "" && token != svc.captchaToken {
+		return fmt.Errorf("invalid captcha token %q", token)
+	}
+	return nil
+}
+
 func (svc *mockService) PostToTwitter(job data.Job) error {
 	svc.tweets = append(svc.tweets, job)
 	return nil
 }
 
-func (svc *mockService) PostToSlack(job data.Job) error {
+func (svc *mockService) PostToSlack(job data.Job) (string, error) {
 	svc.slacks = append(svc.slacks, job)
+	return "", nil
+}
+
+func (svc *mockService) PostExpiredToSlack(job data.Job) error {
+	svc.closed = append(svc.closed, job)
+	return nil
+}
+
+func (svc *mockService) PostJobToDiscord(job data.Job) error {
+	svc.discords = append(svc.discords, job)
+	return nil
+}
+
+func (svc *mockService) PostJobToMastodon(job data.Job) error {
+	svc.toots = append(svc.toots, job)
+	return nil
+}
+
+func (svc *mockService) PostJobToWebhooks(job data.Job) error {
+	svc.webhooks = append(svc.webhooks, job)
 	return nil
 }
 
 func makeServer(t *testing.T) (*httptest.Server, *mockService, sqlmock.Sqlmock, *config.Config) {
-	db, dbmock, err := sqlmock.New()
+	conf := &config.Config{
+		AppSecret:       "sup",
+		Env:             "debug",
+		InboundEmailKey: "inbound",
+		NotifyEmail:     true,
+		NotifySlack:     true,
+		NotifyTwitter:   true,
+	}
+	return makeServerWithConfig(t, conf)
+}
+
+// makeServerWithConfig is for tests that depend on config read when the
+// server is created, rather than per request
+func makeServerWithConfig(t *testing.T, conf *config.Config) (*httptest.Server, *mockService, sqlmock.Sqlmock, *config.Config) {
+	db, dbmock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
 	assert.NoError(t, err)
 
-	conf := &config.Config{AppSecret: "sup", Env: "debug"}
-	svc := &mockService{}
+	notifications := server.NewNotificationQueue(10)
+	t.Cleanup(notifications.Close)
+
+	svc := &mockService{flush: notifications.Flush}
 
 	s, err := server.NewServer(
 		&server.ServerConfig{
-			Config:         conf,
-			DB:             db,
-			EmailService:   svc,
-			TwitterService: svc,
-			SlackService:   svc,
-			TemplatePath:   "../../templates",
+			Config:          conf,
+			DB:              db,
+			EmailService:    svc,
+			TwitterService:  svc,
+			SlackService:    svc,
+			DiscordService:  svc,
+			MastodonService: svc,
+			WebhookService:  svc,
+			CaptchaService:  svc,
+			FileStore:       &services.LocalFileStore{Dir: t.TempDir(), URLPath: "/uploads"},
+			TemplatePath:    "../../templates",
+			AssetPath:       "../../assets",
+			Notifications:   notifications,
 		},
 	)
 	assert.NoError(t, err)
@@ -472,8 +3286,12 @@ func sendRequest(t *testing.T, path string, postBody []byte) (string, *http.Resp
 	if postBody == nil {
 		resp, err = client.Get(path)
 	} else {
-		// TODO: switch this to client.PostForm to simplify
-		resp, err = client.Post(path, "application/x-www-form-urlencoded", bytes.NewReader(postBody))
+		// Posts come from the board's own pages, like a browser's would
+		req, reqErr := http.NewRequest(http.MethodPost, path, bytes.NewReader(postBody))
+		assert.NoError(t, reqErr)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", req.URL.Scheme+"://"+req.URL.Host)
+		resp, err = client.Do(req)
 	}
 
 	assert.NoError(t, err)
@@ -486,10 +3304,63 @@ func sendRequest(t *testing.T, path string, postBody []byte) (string, *http.Resp
 	return string(body), resp
 }
 
+func sendMultipartRequest(t *testing.T, path string, values map[string][]string, fileName string, fileContent []byte) (string, *http.Response) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	for k, vs := range values {
+		for _, v := range vs {
+			assert.NoError(t, w.WriteField(k, v))
+		}
+	}
+
+	if fileName != "" {
+		fw, err := w.CreateFormFile("logo", fileName)
+		assert.NoError(t, err)
+		_, err = fw.Write(fileContent)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+
+	cookieJar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	assert.NoError(t, err)
+
+	client := http.Client{Jar: cookieJar}
+	resp, err := client.Post(path, w.FormDataContentType(), &buf)
+	assert.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	return string(body), resp
+}
+
+// captureArg matches any string argument and records it
+type captureArg struct {
+	value *string
+}
+
+func (a captureArg) Match(v driver.Value) bool {
+	switch val := v.(type) {
+	case string:
+		*a.value = val
+	case sql.NullString:
+		*a.value = val.String
+	default:
+		return false
+	}
+	return true
+}
+
 func resetServiceMock(svc *mockService) {
 	svc.emails = []email{}
 	svc.tweets = []data.Job{}
 	svc.slacks = []data.Job{}
+	svc.closed = []data.Job{}
+	svc.discords = []data.Job{}
+	svc.toots = []data.Job{}
+	svc.webhooks = []data.Job{}
 }
 
 func getDbFields(thing interface{}) []string {
@@ -516,6 +3387,17 @@ func mockJobRow(job data.Job) []driver.Value {
 		sql.NullString{},
 		"example@example.com",
 		time.Now(),
+		false,
+		sql.NullString{},
+		sql.NullString{},
+		job.Confirmed,
+		nil,
+		sql.NullTime{},
+		data.StatusApproved,
+		job.Featured,
+		job.ApplyCount,
+		job.ReminderSent,
+		job.Slug,
 	}
 
 	if job.ID != "" {
@@ -546,9 +3428,80 @@ func mockJobRow(job data.Job) []driver.Value {
 		vals[6] = job.PublishedAt
 	}
 
+	if job.NeedsReview {
+		vals[7] = job.NeedsReview
+	}
+
+	if job.LogoUrl.Valid {
+		vals[8] = job.LogoUrl
+	}
+
+	if job.SlackTS.Valid {
+		vals[9] = job.SlackTS
+	}
+
+	if job.Metadata != nil {
+		vals[11], _ = job.Metadata.Value()
+	}
+
+	if job.DeletedAt.Valid {
+		vals[12] = job.DeletedAt
+	}
+
+	if job.Status != "" {
+		vals[13] = job.Status
+	}
+
 	return vals
 }
 
+func expectAdminQueries(dbmock sqlmock.Sqlmock, deleted []data.Job) {
+	expectAdminQueriesWithPending(dbmock, nil, deleted)
+}
+
+func expectAdminQueriesWithPending(dbmock sqlmock.Sqlmock, pending []data.Job, deleted []data.Job) {
+	pendingRows := sqlmock.NewRows(getDbFields(data.Job{}))
+	for _, job := range pending {
+		pendingRows.AddRow(mockJobRow(job)...)
+	}
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE \(status = 'pending' OR needs_review\)`).
+		WillReturnRows(pendingRows)
+	expectSelectJobsQuery(dbmock, []data.Job{{ID: "3", Position: "Published Pos"}})
+	dbmock.ExpectQuery(`SELECT COUNT\(\*\) FROM jobs WHERE deleted_at IS NULL$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	dbmock.ExpectQuery(`SELECT COUNT\(\*\) FROM jobs WHERE published_at >= \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	dbmock.ExpectQuery(`SELECT COUNT\(\*\) FROM jobs WHERE published_at >= \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(9))
+	dbmock.ExpectQuery(`SELECT organization, COUNT\(\*\) AS jobs FROM jobs`).
+		WillReturnRows(sqlmock.NewRows([]string{"organization", "jobs"}).AddRow("Busy Org", 7))
+	expectSelectJobsQuery(dbmock, []data.Job{{ID: "1", Position: "Expiring Pos"}})
+
+	rows := sqlmock.NewRows(getDbFields(data.Job{}))
+	for _, job := range deleted {
+		rows.AddRow(mockJobRow(job)...)
+	}
+	dbmock.ExpectQuery(`SELECT \* FROM jobs WHERE deleted_at IS NOT NULL`).WillReturnRows(rows)
+
+	dbmock.ExpectQuery(`SELECT audit_log\.\*, COALESCE\(jobs\.position, ''\) AS position\s+FROM audit_log`).
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "job_id", "actor", "action", "created_at", "position"}).
+			AddRow("1", "4", data.ActorPoster, data.AuditUpdate, time.Now(), "Audited Pos").
+			AddRow("2", "5", "admin", data.AuditDelete, time.Now(), ""))
+}
+
+func expectAuditRecord(dbmock sqlmock.Sqlmock, jobID, actor, action string) {
+	dbmock.ExpectExec(`INSERT INTO audit_log \(job_id, actor, action\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(jobID, actor, action).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// en is the English text for a message key, which is what pages are shown
+// in without an Accept-Language header
+func en(key string) string {
+	return i18n.Translate(i18n.DefaultLocale, key)
+}
+
 func expectSelectJobsQuery(dbmock sqlmock.Sqlmock, jobs []data.Job) {
 	rows := sqlmock.NewRows(getDbFields(data.Job{}))
 	for _, job := range jobs {
@@ -2,75 +2,212 @@ package server
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"path"
+	"time"
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
+	"github.com/devict/job-board/pkg/i18n"
 	"github.com/devict/job-board/pkg/services"
 	"github.com/gin-contrib/multitemplate"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 )
 
 type ServerConfig struct {
-	Config         *config.Config
-	DB             *sql.DB
-	EmailService   services.IEmailService
-	TwitterService services.ITwitterService
-	SlackService   services.ISlackService
-	TemplatePath   string
+	Config          *config.Config
+	DB              *sql.DB
+	EmailService    services.IEmailService
+	TwitterService  services.ITwitterService
+	SlackService    services.ISlackService
+	DiscordService  services.IDiscordService
+	MastodonService services.IMastodonService
+	WebhookService  services.IWebhookService
+	CaptchaService  services.ICaptchaService
+	FileStore       services.IFileStore
+	TemplatePath    string
+	AssetPath       string
+
+	// Notifications sends notifications in the background when set,
+	// otherwise they're sent before responding
+	Notifications *NotificationQueue
 }
 
 func NewServer(c *ServerConfig) (http.Server, error) {
 	gin.SetMode(c.Config.Env)
 	gin.DefaultWriter = log.Writer()
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(requestID, gin.LoggerWithFormatter(logRequest), gin.Recovery())
+	if c.Config.Gzip {
+		router.Use(compress)
+	}
 
 	if err := router.SetTrustedProxies(nil); err != nil {
 		return http.Server{}, fmt.Errorf("failed to SetTrustedProxies: %w", err)
 	}
 
+	sessionMaxAge := c.Config.SessionMaxAge
+	if sessionMaxAge <= 0 {
+		sessionMaxAge = defaultSessionMaxAge
+	}
+
 	sessionOpts := sessions.Options{
 		Path:     "/",
-		MaxAge:   24 * 60, // 1 day
+		MaxAge:   int(sessionMaxAge.Seconds()),
 		Secure:   c.Config.Env != "debug",
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
 	}
 
-	sessionStore := cookie.NewStore([]byte(c.Config.AppSecret))
+	sessionStore, err := newSessionStore(c.Config, c.DB)
+	if err != nil {
+		return http.Server{}, err
+	}
 	sessionStore.Options(sessionOpts)
-	router.Use(sessions.Sessions("mysession", sessionStore))
+	sessionName := c.Config.SessionCookieName
+	if sessionName == "" {
+		sessionName = defaultSessionCookieName
+	}
+	router.Use(sessions.Sessions(sessionName, sessionStore))
+	router.Use(announce(c.Config.AnnouncementText, c.Config.AnnouncementURL))
+	router.Use(localize)
+	router.Use(errorPages)
+
+	assetPath := c.AssetPath
+	if assetPath == "" {
+		assetPath = defaultAssetPath
+	}
+	assets := router.Group("/assets")
+	assets.Use(cacheAssets(c.Config.AssetMaxAge))
+	assets.Static("/", assetPath)
+	router.GET("/favicon.ico", cacheAssets(c.Config.AssetMaxAge), favicon(assetPath))
+	router.HEAD("/favicon.ico", cacheAssets(c.Config.AssetMaxAge), favicon(assetPath))
+
+	fileStore := c.FileStore
+	if fileStore == nil {
+		var err error
+		if fileStore, err = services.NewFileStore(c.Config); err != nil {
+			return http.Server{}, fmt.Errorf("failed to NewFileStore: %w", err)
+		}
+	}
+	if local, ok := fileStore.(*services.LocalFileStore); ok {
+		uploads := router.Group(local.URLPath)
+		uploads.Use(serveUploads)
+		uploads.Static("/", local.Dir)
+	}
+	loc := c.Config.DisplayLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	router.HTMLRender = renderer(c.TemplatePath, assetPath, loc)
 
-	router.Static("/assets", "assets")
-	router.HTMLRender = renderer(c.TemplatePath)
+	emails, err := NewEmailRenderer(c.TemplatePath)
+	if err != nil {
+		return http.Server{}, err
+	}
 
 	sqlxDb := sqlx.NewDb(c.DB, "postgres")
 
 	ctrl := &Controller{
-		DB:             sqlxDb,
-		Config:         c.Config,
-		EmailService:   c.EmailService,
-		SlackService:   c.SlackService,
-		TwitterService: c.TwitterService,
+		DB:              sqlxDb,
+		Config:          c.Config,
+		EmailService:    c.EmailService,
+		SlackService:    c.SlackService,
+		DiscordService:  c.DiscordService,
+		MastodonService: c.MastodonService,
+		WebhookService:  c.WebhookService,
+		Notifications:   c.Notifications,
+		TwitterService:  c.TwitterService,
+		CaptchaService:  c.CaptchaService,
+		FileStore:       fileStore,
+		Emails:          emails,
+		JobList:         NewJobListCache(c.Config.JobListCacheTTL, c.Config.DBTimeout),
+		BlockedDomains:  data.NewBlockedDomains(data.DisposableEmailDomains, c.Config.BlockedEmailDomains),
 	}
-	router.GET("/", ctrl.Index)
+	heavy := shedLoad(c.Config.MaxConcurrentHeavyRequests)
+
+	router.NoRoute(ctrl.NotFound)
+	router.GET("/healthz", ctrl.Health)
+	router.GET("/", heavy, ctrl.Index)
 	router.GET("/new", ctrl.NewJob)
-	router.POST("/jobs", ctrl.CreateJob)
-	router.GET("/jobs/:id", ctrl.ViewJob)
+	var onSubmissionLimited func(*gin.Context, time.Duration)
+	if c.Config.RateLimitGrace {
+		onSubmissionLimited = ctrl.HoldJobSubmission
+	}
+
+	router.POST("/jobs", rateLimit(c.Config.SubmissionsPerMinute, onSubmissionLimited), ctrl.CreateJob)
+	router.POST("/preview", rateLimit(previewsPerMinute, nil), ctrl.PreviewDescription)
+	router.GET("/jobs/:id", heavy, ctrl.ViewJob)
+	router.GET("/jobs/:id/:slug", heavy, ctrl.ViewJob)
+	router.GET("/jobs/:id/apply", ctrl.ApplyJob)
+	// A catch-all so organizations with slashes in their names still match
+	router.GET("/orgs/*name", heavy, ctrl.ViewOrganization)
+
+	router.POST("/announcement/dismiss", ctrl.DismissAnnouncement)
+
+	if c.EmailService != nil {
+		router.POST("/subscribe", rateLimit(c.Config.SubmissionsPerMinute, nil), ctrl.Subscribe)
+		router.GET("/subscribe/confirm", ctrl.ConfirmSubscription)
+	}
+	router.GET("/unsubscribe", ctrl.Unsubscribe)
+
+	router.GET("/embed/jobs", heavy, ctrl.EmbedJobs)
+	router.GET("/embed/jobs.js", ctrl.EmbedScript)
+
+	// Monitoring tools check these with HEAD, which skips rendering
+	router.HEAD("/healthz", ctrl.Health)
+	router.HEAD("/", heavy, ctrl.Index)
+	router.HEAD("/jobs/:id", heavy, ctrl.ViewJob)
+	router.HEAD("/jobs/:id/:slug", heavy, ctrl.ViewJob)
+
+	if c.Config.InboundEmailKey != "" {
+		router.POST("/integrations/email/inbound", ctrl.InboundEmail)
+	}
+
+	if len(c.Config.APIKeys) != 0 {
+		api := router.Group("/api")
+		api.Use(cors(c.Config.APIOrigins))
+		// Preflight requests are answered by cors, before they'd need a key
+		api.OPTIONS("/*path", func(ctx *gin.Context) { ctx.Status(http.StatusNoContent) })
+
+		api.Use(apiAuth(c.Config.APIKeys))
+		{
+			api.POST("/v1/jobs", ctrl.APICreateJob)
+
+			// From before the API was versioned
+			api.POST("/jobs", deprecatedFor("/api/v1/jobs"), ctrl.APICreateJob)
+		}
+	}
 
 	authorized := router.Group("/")
-	authorized.Use(requireAuth(sqlxDb, c.Config.AppSecret))
+	authorized.Use(requireAuth(sqlxDb, c.Config.AppSecrets(), c.Config.DBTimeout))
 	{
+		authorized.GET("/jobs/:id/confirm", ctrl.ConfirmJob)
 		authorized.GET("/jobs/:id/edit", ctrl.EditJob)
 		authorized.POST("/jobs/:id", ctrl.UpdateJob)
+		authorized.GET("/jobs/:id/delete", ctrl.ConfirmDeleteJob)
+		authorized.POST("/jobs/:id/delete", ctrl.DeleteJob)
+	}
+
+	if c.Config.AdminEnabled() {
+		admin := router.Group("/admin")
+		admin.Use(adminAuth(c.Config))
+		{
+			admin.GET("", ctrl.AdminIndex)
+			admin.POST("/jobs/:id/approve", ctrl.ApproveJob)
+			admin.POST("/jobs/:id/reject", ctrl.RejectJob)
+			admin.POST("/jobs/:id/feature", ctrl.ToggleFeaturedJob)
+			admin.POST("/jobs/:id/restore", ctrl.RestoreJob)
+			admin.GET("/jobs/:id/revisions", ctrl.JobRevisions)
+			admin.POST("/jobs/:id/revisions/:revision/revert", ctrl.RevertJob)
+		}
 	}
 
 	return http.Server{
@@ -79,37 +216,59 @@ func NewServer(c *ServerConfig) (http.Server, error) {
 	}, nil
 }
 
-func renderer(templatePath string) multitemplate.Renderer {
+// renderer loads the page templates, with dates shown in loc
+func renderer(templatePath, assetPath string, loc *time.Location) multitemplate.Renderer {
 	funcMap := template.FuncMap{
-		"formatAsDate":          formatAsDate,
-		"formatAsRfc3339String": formatAsRfc3339String,
+		"formatAsDate":          func(t time.Time) string { return formatAsDate(t.In(loc)) },
+		"formatAsRfc3339String": func(t time.Time) string { return formatAsRfc3339String(t.In(loc)) },
+		"plaintext":             markdownToPlaintext,
+		"truncate":              truncate,
+		"humanize":              humanize,
+		"orgPath":               orgPath,
+		"initial":               initial,
+		"t":                     i18n.Translate,
+		"localized":             localized,
+		"timeAgo":               timeAgo,
+		"asset":                 assetURL(assetPath),
 	}
 
 	basePath := path.Join(templatePath, "base.html")
 
 	r := multitemplate.NewRenderer()
 	r.AddFromFilesFuncs("index", funcMap, basePath, path.Join(templatePath, "index.html"))
-	r.AddFromFilesFuncs("new", funcMap, basePath, path.Join(templatePath, "new.html"))
-	r.AddFromFilesFuncs("edit", funcMap, basePath, path.Join(templatePath, "edit.html"))
+	previewPath := path.Join(templatePath, "preview.html")
+
+	r.AddFromFilesFuncs("new", funcMap, basePath, path.Join(templatePath, "new.html"), previewPath)
+	r.AddFromFilesFuncs("edit", funcMap, basePath, path.Join(templatePath, "edit.html"), previewPath)
 	r.AddFromFilesFuncs("view", funcMap, basePath, path.Join(templatePath, "view.html"))
+	r.AddFromFilesFuncs("delete", funcMap, basePath, path.Join(templatePath, "delete.html"))
+	r.AddFromFilesFuncs("admin", funcMap, basePath, path.Join(templatePath, "admin.html"))
+	r.AddFromFilesFuncs("revisions", funcMap, basePath, path.Join(templatePath, "revisions.html"))
+	r.AddFromFilesFuncs("unsubscribed", funcMap, basePath, path.Join(templatePath, "unsubscribed.html"))
+	r.AddFromFilesFuncs("notfound", funcMap, basePath, path.Join(templatePath, "notfound.html"))
+	r.AddFromFilesFuncs("error", funcMap, basePath, path.Join(templatePath, "error.html"))
+	r.AddFromFilesFuncs("embed", funcMap, path.Join(templatePath, "embed.html"))
 
 	return r
 }
 
-func requireAuth(db *sqlx.DB, secret string) func(*gin.Context) {
+func requireAuth(db *sqlx.DB, secrets []string, timeout time.Duration) func(*gin.Context) {
 	return func(ctx *gin.Context) {
 		jobID := ctx.Param("id")
-		job, err := data.GetJob(jobID, db)
-		if err != nil {
+		dbCtx, cancel := withDBTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		job, err := data.GetJob(dbCtx, jobID, db)
+		if errors.Is(err, data.ErrJobNotFound) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		} else if err != nil {
 			log.Println(fmt.Errorf("requireAuth failed to getJob: %w", err))
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 
-		token := ctx.Query("token")
-		expected := SignatureForJob(job, secret)
-
-		if token != expected {
+		if !ValidJobSignature(job, ctx.Query("token"), secrets) {
 			ctx.AbortWithStatus(403)
 			return
 		}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-contrib/sessions/postgres"
+	redisstore "github.com/gin-contrib/sessions/redis"
+	"github.com/gomodule/redigo/redis"
+)
+
+// The session cookie's name and lifetime when the config doesn't set them
+const (
+	defaultSessionCookieName = "mysession"
+	defaultSessionMaxAge     = 24 * time.Hour
+)
+
+// newSessionStore keeps sessions in the database when DB_SESSIONS is set,
+// or Redis when REDIS_URL is, so they aren't limited to what fits in a
+// cookie and can be revoked. Otherwise they're kept in the cookie.
+func newSessionStore(c *config.Config, db *sql.DB) (sessions.Store, error) {
+	if c.DBSessions {
+		store, err := postgres.NewStore(db, sessionKeys(c)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the session store: %w", err)
+		}
+		return store, nil
+	}
+
+	if c.RedisURL == "" {
+		return cookie.NewStore(sessionKeys(c)...), nil
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(c.RedisURL)
+		},
+	}
+
+	store, err := redisstore.NewStoreWithPool(pool, sessionKeys(c)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return store, nil
+}
+
+// sessionKeys signs sessions with the current app secret and still accepts
+// ones signed with previous secrets. The keys go in authentication and
+// encryption pairs, and sessions aren't encrypted.
+func sessionKeys(c *config.Config) [][]byte {
+	var keys [][]byte
+	for _, secret := range c.AppSecrets() {
+		keys = append(keys, []byte(secret), nil)
+	}
+	return keys
+}
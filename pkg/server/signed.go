@@ -1,10 +1,15 @@
 package server
 
 import (
+	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
@@ -25,11 +30,129 @@ func SignatureForJob(job data.Job, secret string) string {
 	return string(base64.URLEncoding.EncodeToString(hash.Sum(nil)))
 }
 
+// ExpiringSignatureForJob is a token for job's links that stops working
+// at expires. It's the expiry followed by an HMAC of it and the job, so the
+// expiry can't be changed without invalidating the token.
+func ExpiringSignatureForJob(job data.Job, expires time.Time, secret string) string {
+	ts := strconv.FormatInt(expires.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s:%s:%s:%s", job.ID, job.Email, job.PublishedAt.String(), ts)
+
+	return ts + "." + base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// legacyTokensBefore is when links started expiring. Tokens without an
+// expiry are only accepted for jobs published before then, and once those
+// have all been purged this can go.
+var legacyTokensBefore = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// jobToken signs job's links, expiring after EditLinkLifetime, or when the
+// job does if that isn't set
+func jobToken(job data.Job, c *config.Config) string {
+	expires := job.ExpiresAt()
+	if c.EditLinkLifetime > 0 {
+		expires = time.Now().Add(c.EditLinkLifetime)
+	}
+	return ExpiringSignatureForJob(job, expires, c.AppSecret)
+}
+
+// ValidJobSignature reports whether token was signed for job with any of
+// secrets, and hasn't expired. Tokens from before links expired are still
+// accepted for the jobs they were sent for, which are purged within 30 days
+// anyway.
+func ValidJobSignature(job data.Job, token string, secrets []string) bool {
+	ts, _, expiring := strings.Cut(token, ".")
+
+	var expires time.Time
+	if !expiring && !job.PublishedAt.Before(legacyTokensBefore) {
+		return false
+	} else if expiring {
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return false
+		}
+		if expires = time.Unix(unix, 0); !time.Now().Before(expires) {
+			return false
+		}
+	}
+
+	for _, secret := range secrets {
+		expected := SignatureForJob(job, secret)
+		if expiring {
+			expected = ExpiringSignatureForJob(job, expires, secret)
+		}
+
+		if hmac.Equal([]byte(token), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
 func SignedJobRoute(job data.Job, c *config.Config) string {
 	return fmt.Sprintf(
 		"%s/jobs/%s/edit?token=%s",
 		c.URL,
 		job.ID,
-		url.QueryEscape(SignatureForJob(job, c.AppSecret)),
+		url.QueryEscape(jobToken(job, c)),
+	)
+}
+
+func SignedDeleteRoute(job data.Job, c *config.Config) string {
+	return fmt.Sprintf(
+		"%s/jobs/%s/delete?token=%s",
+		c.URL,
+		job.ID,
+		url.QueryEscape(jobToken(job, c)),
+	)
+}
+
+func SignedConfirmRoute(job data.Job, c *config.Config) string {
+	return fmt.Sprintf(
+		"%s/jobs/%s/confirm?token=%s",
+		c.URL,
+		job.ID,
+		url.QueryEscape(jobToken(job, c)),
+	)
+}
+
+// SignatureForEmail is an HMAC of the address keyed with the app secret, so
+// links sent to subscribers can't be forged for other addresses
+func SignatureForEmail(email, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.ToLower(email)))
+
+	return base64.URLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidEmailSignature reports whether token is SignatureForEmail(email)
+// for any of secrets
+func ValidEmailSignature(email, token string, secrets []string) bool {
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(token), []byte(SignatureForEmail(email, secret))) {
+			return true
+		}
+	}
+	return false
+}
+
+func SignedUnsubscribeRoute(email string, c *config.Config) string {
+	return fmt.Sprintf(
+		"%s/unsubscribe?email=%s&token=%s",
+		c.URL,
+		url.QueryEscape(email),
+		url.QueryEscape(SignatureForEmail(email, c.AppSecret)),
+	)
+}
+
+// SignedSubscribeConfirmRoute's token is signed for a different purpose than
+// the unsubscribe token, so one can't be used in place of the other
+func SignedSubscribeConfirmRoute(email string, c *config.Config) string {
+	return fmt.Sprintf(
+		"%s/subscribe/confirm?email=%s&token=%s",
+		c.URL,
+		url.QueryEscape(email),
+		url.QueryEscape(SignatureForEmail("subscribe:"+email, c.AppSecret)),
 	)
 }
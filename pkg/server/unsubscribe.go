@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/devict/job-board/pkg/data"
+	"github.com/gin-gonic/gin"
+)
+
+// Unsubscribe removes the address from the subscribers table. It's safe to
+// follow the same link twice, so it doesn't say whether anyone was removed.
+func (ctrl *Controller) Unsubscribe(ctx *gin.Context) {
+	dbCtx, cancel := ctrl.dbContext(ctx)
+	defer cancel()
+
+	email := ctx.Query("email")
+	if email == "" || !ValidEmailSignature(email, ctx.Query("token"), ctrl.Config.AppSecrets()) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	if _, err := data.Unsubscribe(dbCtx, email, ctrl.DB); err != nil {
+		log.Println(fmt.Errorf("failed to unsubscribe: %w", err))
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.HTML(200, "unsubscribed", addFlash(ctx, gin.H{"email": email}))
+}
@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/devict/job-board/pkg/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsubscribe(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	unsubscribeURL := server.SignedUnsubscribeRoute("test@example.com", conf)
+
+	// Following the link again after it's worked still shows the page
+	for _, removed := range []int64{1, 0} {
+		dbmock.ExpectExec(`DELETE FROM subscribers WHERE email = \$1`).
+			WithArgs("test@example.com").
+			WillReturnResult(sqlmock.NewResult(0, removed))
+
+		body, resp := sendRequest(t, unsubscribeURL, nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, body, "You've been unsubscribed")
+		assert.Contains(t, body, "test@example.com won't get any more emails")
+		assert.NoError(t, dbmock.ExpectationsWereMet())
+	}
+}
+
+func TestUnsubscribeInvalidToken(t *testing.T) {
+	s, _, dbmock, conf := makeServer(t)
+	defer s.Close()
+
+	unsubscribeURL := server.SignedUnsubscribeRoute("test@example.com", conf)
+
+	tests := map[string]string{
+		"other email":    strings.Replace(unsubscribeURL, "test%40example.com", "other%40example.com", 1),
+		"tampered token": strings.Replace(unsubscribeURL, "token=", "token=x", 1),
+		"no token":       unsubscribeURL[:strings.Index(unsubscribeURL, "&token=")],
+		"no email":       s.URL + "/unsubscribe?token=" + server.SignatureForEmail("", conf.AppSecret),
+		// the subscribe confirmation token isn't valid here
+		"wrong purpose": s.URL + "/unsubscribe?email=test%40example.com&token=" +
+			server.SignatureForEmail("subscribe:test@example.com", conf.AppSecret),
+	}
+
+	for name, path := range tests {
+		_, resp := sendRequest(t, path, nil)
+		assert.Equal(t, 403, resp.StatusCode, name)
+	}
+
+	assert.NoError(t, dbmock.ExpectationsWereMet())
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/devict/job-board/pkg/config"
+)
+
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+var captchaVerifyURLs = map[string]string{
+	"hcaptcha":  "https://hcaptcha.com/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+}
+
+type ICaptchaService interface {
+	VerifyCaptcha(token, remoteIP string) error
+}
+
+type CaptchaService struct {
+	Conf *config.Config
+	// VerifyURL overrides the provider's verify endpoint
+	VerifyURL string
+}
+
+type captchaResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (svc *CaptchaService) VerifyCaptcha(token, remoteIP string) error {
+	if token == "" {
+		return ErrCaptchaFailed
+	}
+
+	verifyURL := svc.VerifyURL
+	if verifyURL == "" {
+		verifyURL = captchaVerifyURLs[svc.Conf.CaptchaProvider]
+	}
+
+	resp, err := http.PostForm(verifyURL, url.Values{
+		"secret":   {svc.Conf.CaptchaSecret},
+		"response": {token},
+		"remoteip": {remoteIP},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result captchaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode captcha response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+
+	return nil
+}
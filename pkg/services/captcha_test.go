@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCaptcha(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "shh", r.PostForm.Get("secret"))
+		assert.Equal(t, "127.0.0.1", r.PostForm.Get("remoteip"))
+
+		if r.PostForm.Get("response") == "valid" {
+			w.Write([]byte(`{"success":true}`))
+			return
+		}
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer provider.Close()
+
+	svc := &CaptchaService{
+		Conf:      &config.Config{CaptchaSecret: "shh", CaptchaProvider: "hcaptcha"},
+		VerifyURL: provider.URL,
+	}
+
+	assert.NoError(t, svc.VerifyCaptcha("valid", "127.0.0.1"))
+
+	err := svc.VerifyCaptcha("invalid", "127.0.0.1")
+	assert.True(t, errors.Is(err, ErrCaptchaFailed))
+	assert.ErrorContains(t, err, "invalid-input-response")
+
+	// A missing token fails without asking the provider
+	assert.True(t, errors.Is(svc.VerifyCaptcha("", "127.0.0.1"), ErrCaptchaFailed))
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+)
+
+// discordEmbedColor is the accent color on the left edge of job embeds
+const discordEmbedColor = 0x5865f2
+
+type IDiscordService interface {
+	PostJobToDiscord(data.Job) error
+}
+
+type DiscordService struct {
+	Conf *config.Config
+}
+
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds"`
+}
+
+type DiscordEmbed struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	Color       int    `json:"color"`
+}
+
+func (svc *DiscordService) PostJobToDiscord(job data.Job) error {
+	messageStr, err := json.Marshal(discordMessageFromJob(job, svc.Conf))
+	if err != nil {
+		return fmt.Errorf("failed to marshal discord message: %w", err)
+	}
+
+	resp, err := http.Post(svc.Conf.DiscordHook, "application/json", bytes.NewReader(messageStr))
+	if err != nil {
+		return fmt.Errorf("failed to post to discord: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("discord rejected message with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func discordMessageFromJob(job data.Job, c *config.Config) DiscordMessage {
+	return DiscordMessage{
+		Content: "A new job was posted!",
+		Embeds: []DiscordEmbed{{
+			Title:       fmt.Sprintf("%s @ %s", job.Position, job.Organization),
+			URL:         c.URL + job.Path(),
+			Description: fmt.Sprintf("More info at %s%s", c.URL, job.Path()),
+			Color:       discordEmbedColor,
+		}},
+	}
+}
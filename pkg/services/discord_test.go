@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscordEmbed(t *testing.T) {
+	var received []DiscordMessage
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg DiscordMessage
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		received = append(received, msg)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+
+	svc := &DiscordService{
+		Conf: &config.Config{URL: "https://jobs.devict.org", DiscordHook: hook.URL},
+	}
+
+	err := svc.PostJobToDiscord(data.Job{ID: "1", Position: "Pos", Organization: "Org"})
+	assert.NoError(t, err)
+
+	if assert.Len(t, received, 1) && assert.Len(t, received[0].Embeds, 1) {
+		embed := received[0].Embeds[0]
+		assert.Equal(t, "Pos @ Org", embed.Title)
+		assert.Equal(t, "https://jobs.devict.org/jobs/1", embed.URL)
+	}
+}
+
+func TestDiscordError(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer hook.Close()
+
+	svc := &DiscordService{Conf: &config.Config{DiscordHook: hook.URL}}
+
+	err := svc.PostJobToDiscord(data.Job{ID: "1"})
+	assert.ErrorContains(t, err, "400")
+}
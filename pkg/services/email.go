@@ -1,11 +1,17 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
+	"regexp"
 	"strings"
 
 	"github.com/devict/job-board/pkg/config"
+	"golang.org/x/net/html"
 )
 
 type IEmailService interface {
@@ -16,16 +22,102 @@ type EmailService struct {
 	Conf *config.EmailConfig
 }
 
+// SendEmail sends body, which is html, along with a plaintext version of it
+// for mail clients that don't show html
 func (svc *EmailService) SendEmail(recipient, subject, body string) error {
-	msg := fmt.Sprintf(
-		"From: devICT Job Board <%s>\nTo: %s\nSubject: %s\nContent-Type: text/html; charset=UTF-8\n\n%s",
-		svc.Conf.FromEmail,
+	msg, err := emailMessage(svc.Conf.FromEmail, recipient, subject, body)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+
+	host := strings.Split(svc.Conf.SMTPHost, ":")[0]
+	auth := smtp.PlainAuth("", svc.Conf.SMTPUsername, svc.Conf.SMTPPassword, host)
+	return smtp.SendMail(svc.Conf.SMTPHost, auth, svc.Conf.FromEmail, []string{recipient}, msg)
+}
+
+// emailMessage builds a multipart/alternative message with a text/plain
+// part generated from htmlBody followed by htmlBody itself
+func emailMessage(from, recipient, subject, htmlBody string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(
+		&buf,
+		"From: devICT Job Board <%s>\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n",
+		from,
 		recipient,
 		subject,
-		body,
+		mw.Boundary(),
 	)
 
-	host := strings.Split(svc.Conf.SMTPHost, ":")[0]
-	auth := smtp.PlainAuth("", svc.Conf.SMTPUsername, svc.Conf.SMTPPassword, host)
-	return smtp.SendMail(svc.Conf.SMTPHost, auth, svc.Conf.FromEmail, []string{recipient}, []byte(msg))
+	parts := []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", plaintextFromHTML(htmlBody)},
+		{"text/html; charset=UTF-8", htmlBody},
+	}
+	for _, part := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var extraNewlines = regexp.MustCompile(`\n{3,}`)
+
+// plaintextFromHTML strips the tags from an html email, putting each link's
+// url in parentheses after its text and a blank line after each paragraph
+func plaintextFromHTML(body string) string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return body
+	}
+
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			b.WriteString("\n")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		if n.Type != html.ElementNode {
+			return
+		}
+		switch n.Data {
+		case "a":
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					fmt.Fprintf(&b, " (%s)", attr.Val)
+				}
+			}
+		case "p", "div", "li", "h1", "h2", "h3":
+			b.WriteString("\n\n")
+		}
+	}
+	walk(doc)
+
+	return strings.TrimSpace(extraNewlines.ReplaceAllString(b.String(), "\n\n"))
 }
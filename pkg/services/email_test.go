@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailMessage(t *testing.T) {
+	body := "<p>Your job has been created!</p>\n<p><a href=\"https://jobs.devict.org/jobs/1/edit?token=abc%3D\">Use this link to edit the job posting</a></p>"
+
+	raw, err := emailMessage("jobs@devict.org", "test@example.com", "Job Created!", body)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "test@example.com", msg.Header.Get("To"))
+	assert.Equal(t, "Job Created!", msg.Header.Get("Subject"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// the reader decodes quoted-printable parts itself, but line breaks
+		// are sent as CRLF
+		content, err := io.ReadAll(part)
+		assert.NoError(t, err)
+		parts[part.Header.Get("Content-Type")] = strings.ReplaceAll(string(content), "\r\n", "\n")
+	}
+
+	assert.Equal(t, body, parts["text/html; charset=UTF-8"])
+	assert.Equal(
+		t,
+		"Your job has been created!\n\nUse this link to edit the job posting (https://jobs.devict.org/jobs/1/edit?token=abc%3D)",
+		parts["text/plain; charset=UTF-8"],
+	)
+}
+
+func TestPlaintextFromHTML(t *testing.T) {
+	// the inline emails rely on newlines rather than paragraphs
+	assert.Equal(
+		t,
+		"Thanks for posting a job!\n\nConfirm & publish (https://jobs.devict.org/jobs/1/confirm)",
+		plaintextFromHTML("Thanks for posting a job!\n\n<a href=\"https://jobs.devict.org/jobs/1/confirm\">Confirm &amp; publish</a>"),
+	)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+)
+
+// mastodonMaxLength is the default status length limit on Mastodon
+// instances
+const mastodonMaxLength = 500
+
+type IMastodonService interface {
+	PostJobToMastodon(data.Job) error
+}
+
+type MastodonService struct {
+	Conf *config.Config
+}
+
+func (svc *MastodonService) PostJobToMastodon(job data.Job) error {
+	form := url.Values{"status": {statusFromJob(job, svc.Conf)}}
+
+	endpoint := strings.TrimSuffix(svc.Conf.MastodonURL, "/") + "/api/v1/statuses"
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build mastodon request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+svc.Conf.MastodonToken)
+	// Retried posts with the same key are only published once
+	req.Header.Set("Idempotency-Key", "job-"+job.ID)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to mastodon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("mastodon rejected status with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// statusFromJob builds the status text, shortening the position and
+// organization if needed so that the job link always fits.
+func statusFromJob(job data.Job, c *config.Config) string {
+	head := []rune(fmt.Sprintf("A job was posted! -- %s at %s", job.Position, job.Organization))
+	tail := fmt.Sprintf("\n\nMore info at %s%s", c.URL, job.Path())
+
+	if room := mastodonMaxLength - len([]rune(tail)); len(head) > room {
+		head = append(head[:room-1], '…')
+	}
+
+	return string(head) + tail
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusFromJob(t *testing.T) {
+	conf := &config.Config{URL: "https://jobs.devict.org"}
+
+	status := statusFromJob(data.Job{ID: "1", Position: "Pos", Organization: "Org"}, conf)
+	assert.Equal(t, "A job was posted! -- Pos at Org\n\nMore info at https://jobs.devict.org/jobs/1", status)
+
+	long := data.Job{ID: "2", Position: strings.Repeat("Very Senior ", 50), Organization: "Org"}
+	status = statusFromJob(long, conf)
+	assert.Equal(t, mastodonMaxLength, utf8.RuneCountInString(status))
+	assert.True(t, strings.HasSuffix(status, "…\n\nMore info at https://jobs.devict.org/jobs/2"))
+}
+
+func TestPostJobToMastodon(t *testing.T) {
+	var status string
+	instance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/statuses", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		status = r.PostFormValue("status")
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer instance.Close()
+
+	svc := &MastodonService{
+		Conf: &config.Config{URL: "https://jobs.devict.org", MastodonURL: instance.URL, MastodonToken: "token"},
+	}
+
+	assert.NoError(t, svc.PostJobToMastodon(data.Job{ID: "1", Position: "Pos", Organization: "Org"}))
+	assert.Contains(t, status, "Pos at Org")
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/devict/job-board/pkg/config"
+)
+
+// renderNotification formats fields with a template from the config, which
+// was already checked to render when the config was loaded
+func renderNotification(tmpl string, fields config.NotificationFields) (string, error) {
+	t, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse notification template: %w", err)
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to render notification template: %w", err)
+	}
+
+	return b.String(), nil
+}
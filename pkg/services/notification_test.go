@@ -0,0 +1,49 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationTemplates(t *testing.T) {
+	c := &config.Config{
+		URL:           "https://jobs.devict.org",
+		TweetTemplate: "{{ .Organization }} is hiring a {{ .Position }}: {{ .Url }}",
+		SlackTemplate: "*{{ .Position }}* ({{ .Organization }}) {{ .Url }}",
+	}
+	job := data.Job{ID: "1", Position: "Pos", Organization: "Org"}
+
+	tweet, err := tweetFromJob(job, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "Org is hiring a Pos: https://jobs.devict.org/jobs/1", tweet)
+
+	message, err := slackMessageFromJob(job, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "*Pos* (Org) https://jobs.devict.org/jobs/1", message.Text)
+	assert.Empty(t, message.Blocks)
+
+	// Fields that don't exist only fail when the template is rendered
+	c.TweetTemplate = "{{ .Salary }}"
+	_, err = tweetFromJob(job, c)
+	assert.Error(t, err)
+
+	// Tweets that are too long are cut down to fit, keeping the link
+	c.TweetTemplate = "{{ .Url }} {{ .Position }}"
+	job.Position = strings.Repeat("word ", 100)
+	tweet, err = tweetFromJob(job, c)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(tweet, "https://jobs.devict.org/jobs/1"), tweet)
+	assert.LessOrEqual(t, utf8.RuneCountInString(tweet)-len("https://jobs.devict.org/jobs/1")+tweetURLLength, tweetLength)
+
+	// Jobs can't add links or mentions to Slack posts
+	job.Position = "<!channel> <https://evil.example|click>"
+	message, err = slackMessageFromJob(job, c)
+	assert.NoError(t, err)
+	assert.NotContains(t, message.Text, "<")
+	assert.Contains(t, message.Text, "&lt;!channel&gt;")
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Retry calls fn up to attempts times until it succeeds, waiting an
+// exponentially growing, jittered delay starting around baseDelay between
+// tries. It gives up early, returning the last error, once ctx is done, so
+// a deadline on ctx bounds the total time spent.
+func Retry(ctx context.Context, attempts int, baseDelay time.Duration, fn func() error) error {
+	var err error
+	delay := baseDelay
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if i == attempts-1 {
+			break
+		}
+
+		// Wait somewhere between half and all of the delay so that
+		// retries from concurrent requests don't line up
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+	}
+
+	return err
+}
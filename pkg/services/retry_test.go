@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), 3, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("flaky failure %d", calls)
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), 3, time.Millisecond, func() error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	})
+
+	assert.EqualError(t, err, "failure 3")
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := Retry(ctx, 5, time.Second, func() error {
+		calls++
+		return fmt.Errorf("failure %d", calls)
+	})
+
+	assert.EqualError(t, err, "failure 1")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+)
+
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// sendGridTimeout is how long sending a single email through SendGrid can
+// take
+const sendGridTimeout = 30 * time.Second
+
+// SendGridService sends email through the SendGrid API rather than SMTP
+type SendGridService struct {
+	Conf *config.EmailConfig
+	// APIURL overrides SendGrid's mail send endpoint
+	APIURL string
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// SendEmail sends body, which is html, along with a plaintext version of it
+// for mail clients that don't show html
+func (svc *SendGridService) SendEmail(recipient, subject, body string) error {
+	msg := sendGridMessage{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: recipient}}}},
+		From:             sendGridAddress{Email: svc.Conf.FromEmail, Name: "devICT Job Board"},
+		Subject:          subject,
+		Content: []sendGridContent{
+			{Type: "text/plain", Value: plaintextFromHTML(body)},
+			{Type: "text/html", Value: body},
+		},
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sendgrid message: %w", err)
+	}
+
+	apiURL := svc.APIURL
+	if apiURL == "" {
+		apiURL = sendGridURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create sendgrid request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+svc.Conf.SendGridAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: sendGridTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email through sendgrid: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sendgrid rejected email with status %d: %s", resp.StatusCode, detail)
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendGridSendEmail(t *testing.T) {
+	var received sendGridMessage
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer api.Close()
+
+	svc := &SendGridService{
+		Conf:   &config.EmailConfig{FromEmail: "jobs@devict.org", SendGridAPIKey: "key"},
+		APIURL: api.URL,
+	}
+
+	err := svc.SendEmail("poster@example.com", "Job created", "<p>Your job is <strong>live</strong></p>")
+	assert.NoError(t, err)
+
+	assert.Equal(t, sendGridMessage{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: "poster@example.com"}}}},
+		From:             sendGridAddress{Email: "jobs@devict.org", Name: "devICT Job Board"},
+		Subject:          "Job created",
+		Content: []sendGridContent{
+			{Type: "text/plain", Value: "Your job is live"},
+			{Type: "text/html", Value: "<p>Your job is <strong>live</strong></p>"},
+		},
+	}, received)
+}
+
+func TestSendGridError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errors":[{"message":"bad key"}]}`))
+	}))
+	defer api.Close()
+
+	svc := &SendGridService{Conf: &config.EmailConfig{FromEmail: "jobs@devict.org"}, APIURL: api.URL}
+
+	err := svc.SendEmail("poster@example.com", "Job created", "<p>Hi</p>")
+	assert.ErrorContains(t, err, "401")
+	assert.ErrorContains(t, err, "bad key")
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+	"github.com/devict/job-board/pkg/config"
+)
+
+// sesTimeout is how long sending a single email through SES can take
+const sesTimeout = 30 * time.Second
+
+// sesAPI is the part of the SES client SESService uses
+type sesAPI interface {
+	SendEmail(context.Context, *sesv2.SendEmailInput, ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error)
+}
+
+// SESService sends email through Amazon SES rather than SMTP
+type SESService struct {
+	Conf   *config.EmailConfig
+	Client sesAPI
+}
+
+// NewSESService creates an SES client from the usual AWS environment
+// variables, shared config, or instance role, in SES_REGION if it's set
+func NewSESService(conf *config.EmailConfig) (*SESService, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if conf.SESRegion != "" {
+		opts = append(opts, awsconfig.WithRegion(conf.SESRegion))
+	}
+
+	awsConf, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	return &SESService{Conf: conf, Client: sesv2.NewFromConfig(awsConf)}, nil
+}
+
+// SendEmail sends body, which is html, along with a plaintext version of it
+// for mail clients that don't show html
+func (svc *SESService) SendEmail(recipient, subject, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sesTimeout)
+	defer cancel()
+
+	_, err := svc.Client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(fmt.Sprintf("devICT Job Board <%s>", svc.Conf.FromEmail)),
+		Destination:      &types.Destination{ToAddresses: []string{recipient}},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: sesContent(subject),
+				Body: &types.Body{
+					Text: sesContent(plaintextFromHTML(body)),
+					Html: sesContent(body),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email through ses: %w", err)
+	}
+
+	return nil
+}
+
+func sesContent(s string) *types.Content {
+	return &types.Content{Data: aws.String(s), Charset: aws.String("UTF-8")}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/devict/job-board/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSES struct {
+	sent []*sesv2.SendEmailInput
+	err  error
+}
+
+func (m *mockSES) SendEmail(ctx context.Context, input *sesv2.SendEmailInput, opts ...func(*sesv2.Options)) (*sesv2.SendEmailOutput, error) {
+	m.sent = append(m.sent, input)
+	return &sesv2.SendEmailOutput{}, m.err
+}
+
+func TestSESSendEmail(t *testing.T) {
+	client := &mockSES{}
+	svc := &SESService{Conf: &config.EmailConfig{FromEmail: "jobs@devict.org"}, Client: client}
+
+	err := svc.SendEmail("poster@example.com", "Job created", "<p>Your job is <strong>live</strong></p>")
+	assert.NoError(t, err)
+
+	if !assert.Len(t, client.sent, 1) {
+		return
+	}
+	input := client.sent[0]
+	assert.Equal(t, "devICT Job Board <jobs@devict.org>", aws.ToString(input.FromEmailAddress))
+	assert.Equal(t, []string{"poster@example.com"}, input.Destination.ToAddresses)
+
+	msg := input.Content.Simple
+	assert.Equal(t, "Job created", aws.ToString(msg.Subject.Data))
+	assert.Equal(t, "<p>Your job is <strong>live</strong></p>", aws.ToString(msg.Body.Html.Data))
+	assert.Equal(t, "Your job is live", aws.ToString(msg.Body.Text.Data))
+	assert.Equal(t, "UTF-8", aws.ToString(msg.Body.Html.Charset))
+}
+
+func TestSESError(t *testing.T) {
+	client := &mockSES{err: errors.New("MessageRejected: Email address is not verified")}
+	svc := &SESService{Conf: &config.EmailConfig{FromEmail: "jobs@devict.org"}, Client: client}
+
+	err := svc.SendEmail("poster@example.com", "Job created", "<p>Hi</p>")
+	assert.ErrorContains(t, err, "not verified")
+}
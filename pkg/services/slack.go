@@ -5,43 +5,204 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
 )
 
+const slackAPIURL = "https://slack.com/api"
+
 type ISlackService interface {
-	PostToSlack(data.Job) error
+	// PostToSlack announces a new job, returning the message timestamp when
+	// Slack provides one (only the Web API does, webhooks don't).
+	PostToSlack(data.Job) (string, error)
+	// PostExpiredToSlack announces that a job is no longer open, threaded
+	// under the original announcement when its timestamp is known.
+	PostExpiredToSlack(data.Job) error
 }
 
 type SlackService struct {
 	Conf *config.Config
+	// APIURL overrides the Slack Web API base url, defaults to slackAPIURL
+	APIURL string
 }
 
 type SlackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	// Text is shown in notifications, and in place of Blocks where they
+	// can't be
+	Text     string       `json:"text"`
+	Blocks   []SlackBlock `json:"blocks,omitempty"`
+	ThreadTS string       `json:"thread_ts,omitempty"`
+}
+
+// SlackBlock is a Block Kit layout block, only covering the header, section
+// and actions blocks used for job announcements
+type SlackBlock struct {
+	Type     string         `json:"type"`
+	Text     *SlackText     `json:"text,omitempty"`
+	Elements []SlackElement `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
 	Text string `json:"text"`
 }
 
-func (svc *SlackService) PostToSlack(job data.Job) error {
-	message := slackMessageFromJob(job, svc.Conf)
+// SlackElement is a block element, only covering link buttons
+type SlackElement struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text"`
+	URL  string     `json:"url"`
+}
+
+type slackAPIResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+func (svc *SlackService) PostToSlack(job data.Job) (string, error) {
+	message, err := slackMessageFromJob(job, svc.Conf)
+	if err != nil {
+		return "", err
+	}
+
+	return svc.send(message)
+}
+
+func (svc *SlackService) PostExpiredToSlack(job data.Job) error {
+	message := slackExpiredMessageFromJob(job)
+	if job.SlackTS.Valid {
+		message.ThreadTS = job.SlackTS.String
+	}
+
+	_, err := svc.send(message)
+	return err
+}
+
+func (svc *SlackService) send(message SlackMessage) (string, error) {
+	if svc.Conf.SlackToken != "" {
+		return svc.postMessage(message)
+	}
+
 	messageStr, err := json.Marshal(message)
 	if err != nil {
-		return fmt.Errorf("failed to marshal slack message: %w", err)
+		return "", fmt.Errorf("failed to marshal slack message: %w", err)
 	}
 
 	_, err = http.Post(svc.Conf.SlackHook, "application/json", bytes.NewReader(messageStr))
 	if err != nil {
-		return fmt.Errorf("failed to post to slack: %w", err)
+		return "", fmt.Errorf("failed to post to slack: %w", err)
+	}
+
+	return "", nil
+}
+
+// postMessage sends the message through the Web API's chat.postMessage,
+// which, unlike webhooks, tells us the timestamp of the posted message.
+func (svc *SlackService) postMessage(message SlackMessage) (string, error) {
+	message.Channel = svc.Conf.SlackChannel
+
+	messageStr, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal slack message: %w", err)
 	}
 
-	return nil
+	apiURL := svc.APIURL
+	if apiURL == "" {
+		apiURL = slackAPIURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, apiURL+"/chat.postMessage", bytes.NewReader(messageStr))
+	if err != nil {
+		return "", fmt.Errorf("failed to build slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+svc.Conf.SlackToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to post to slack: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp slackAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return "", fmt.Errorf("failed to decode slack response: %w", err)
+	}
+
+	if !apiResp.OK {
+		return "", fmt.Errorf("slack rejected message: %s", apiResp.Error)
+	}
+
+	return apiResp.TS, nil
+}
+
+// slackDescriptionLength is how much of a job's description is included in
+// its announcement
+const slackDescriptionLength = 200
+
+// slackMessageFromJob uses SLACK_TEMPLATE when it's set, as mrkdwn text in
+// place of the blocks. What it renders is escaped, so a job's fields can't
+// add links or mentions.
+func slackMessageFromJob(job data.Job, c *config.Config) (SlackMessage, error) {
+	if c.SlackTemplate != "" {
+		text, err := renderNotification(c.SlackTemplate, config.NotificationFields{
+			Position:     job.Position,
+			Organization: job.Organization,
+			Url:          c.URL + job.Path(),
+		})
+		return SlackMessage{Text: slackEscape(text)}, err
+	}
+
+	jobURL := c.URL + job.Path()
+	text := fmt.Sprintf(
+		"A new job was posted!\n> *<%s|%s @ %s>*",
+		jobURL,
+		job.Position,
+		job.Organization,
+	)
+
+	details := "*" + slackEscape(job.Organization) + "*"
+	if job.Description.Valid {
+		details += "\n" + slackEscape(truncate(slackDescriptionLength, job.Description.String))
+	}
+
+	return SlackMessage{
+		Text: text,
+		Blocks: []SlackBlock{
+			{
+				Type: "header",
+				// headers are limited to 150 characters
+				Text: &SlackText{Type: "plain_text", Text: truncate(150, job.Position)},
+			},
+			{
+				Type: "section",
+				Text: &SlackText{Type: "mrkdwn", Text: details},
+			},
+			{
+				Type: "actions",
+				Elements: []SlackElement{{
+					Type: "button",
+					Text: &SlackText{Type: "plain_text", Text: "View job"},
+					URL:  jobURL,
+				}},
+			},
+		},
+	}, nil
+}
+
+// slackEscape escapes the characters Slack treats as control characters in
+// mrkdwn text
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
 }
 
-func slackMessageFromJob(job data.Job, c *config.Config) SlackMessage {
+func slackExpiredMessageFromJob(job data.Job) SlackMessage {
 	text := fmt.Sprintf(
-		"A new job was posted!\n> *<%s/jobs/%s|%s @ %s>*",
-		c.URL,
-		job.ID,
+		"This job is no longer open: %s @ %s",
 		job.Position,
 		job.Organization,
 	)
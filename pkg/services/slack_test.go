@@ -0,0 +1,130 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlackExpiredFollowUp(t *testing.T) {
+	var received []SlackMessage
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.postMessage", r.URL.Path)
+		assert.Equal(t, "Bearer xoxb-token", r.Header.Get("Authorization"))
+
+		var msg SlackMessage
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		received = append(received, msg)
+
+		w.Write([]byte(`{"ok":true,"ts":"1665964800.000100"}`))
+	}))
+	defer api.Close()
+
+	svc := &SlackService{
+		Conf: &config.Config{
+			URL:          "https://jobs.devict.org",
+			SlackToken:   "xoxb-token",
+			SlackChannel: "#jobs",
+		},
+		APIURL: api.URL,
+	}
+
+	job := data.Job{ID: "1", Position: "Pos", Organization: "Org"}
+
+	ts, err := svc.PostToSlack(job)
+	assert.NoError(t, err)
+	assert.Equal(t, "1665964800.000100", ts)
+
+	job.SlackTS = sql.NullString{String: ts, Valid: true}
+	assert.NoError(t, svc.PostExpiredToSlack(job))
+
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, "#jobs", received[0].Channel)
+		assert.Empty(t, received[0].ThreadTS)
+
+		assert.Equal(t, "#jobs", received[1].Channel)
+		assert.Equal(t, "1665964800.000100", received[1].ThreadTS)
+		assert.Contains(t, received[1].Text, "no longer open")
+		assert.Contains(t, received[1].Text, "Pos @ Org")
+	}
+}
+
+func TestSlackAPIError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	defer api.Close()
+
+	svc := &SlackService{
+		Conf:   &config.Config{SlackToken: "xoxb-token", SlackChannel: "#nope"},
+		APIURL: api.URL,
+	}
+
+	_, err := svc.PostToSlack(data.Job{ID: "1"})
+	assert.ErrorContains(t, err, "channel_not_found")
+}
+
+func TestSlackMessageBlocks(t *testing.T) {
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org <& Co>",
+		Description:  sql.NullString{String: strings.Repeat("Lots to say about the role. ", 20), Valid: true},
+	}
+
+	message, err := slackMessageFromJob(job, &config.Config{URL: "https://jobs.devict.org"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	payload, err := json.Marshal(message)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var msg struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"text"`
+			Elements []struct {
+				Type string `json:"type"`
+				URL  string `json:"url"`
+			} `json:"elements"`
+		} `json:"blocks"`
+	}
+	if !assert.NoError(t, json.Unmarshal(payload, &msg)) {
+		return
+	}
+
+	assert.Contains(t, msg.Text, "Pos @ Org")
+	if !assert.Len(t, msg.Blocks, 3) {
+		return
+	}
+
+	assert.Equal(t, "header", msg.Blocks[0].Type)
+	assert.Equal(t, "plain_text", msg.Blocks[0].Text.Type)
+	assert.Equal(t, "Pos", msg.Blocks[0].Text.Text)
+
+	assert.Equal(t, "section", msg.Blocks[1].Type)
+	assert.Equal(t, "mrkdwn", msg.Blocks[1].Text.Type)
+	assert.True(t, strings.HasPrefix(msg.Blocks[1].Text.Text, "*Org &lt;&amp; Co&gt;*\nLots to say"))
+	assert.True(t, strings.HasSuffix(msg.Blocks[1].Text.Text, "…"))
+	assert.Less(t, len([]rune(msg.Blocks[1].Text.Text)), 250)
+
+	assert.Equal(t, "actions", msg.Blocks[2].Type)
+	if assert.Len(t, msg.Blocks[2].Elements, 1) {
+		assert.Equal(t, "button", msg.Blocks[2].Elements[0].Type)
+		assert.Equal(t, "https://jobs.devict.org/jobs/1", msg.Blocks[2].Elements[0].URL)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/devict/job-board/pkg/config"
+)
+
+// s3Timeout is how long uploading a single file to S3 can take
+const s3Timeout = 30 * time.Second
+
+type IFileStore interface {
+	// Save stores the contents of r under name and returns the URL it can
+	// be retrieved from.
+	Save(name string, r io.Reader, contentType string) (string, error)
+}
+
+// NewFileStore picks where uploads are stored from c: an S3-compatible
+// bucket when S3.Bucket is set, otherwise UploadDir, served under
+// /uploads. It returns nil when uploads are disabled.
+func NewFileStore(c *config.Config) (IFileStore, error) {
+	if c.S3 != nil && c.S3.Bucket != "" {
+		store, err := NewS3FileStore(c.S3)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	if c.UploadDir != "" {
+		return &LocalFileStore{Dir: c.UploadDir, URLPath: "/uploads"}, nil
+	}
+
+	return nil, nil
+}
+
+type LocalFileStore struct {
+	// Dir is where files are written on disk
+	Dir string
+	// URLPath is the route Dir is served under
+	URLPath string
+}
+
+// Save writes r to Dir. The content type isn't kept, since files are served
+// with the type their extension implies.
+func (svc *LocalFileStore) Save(name string, r io.Reader, contentType string) (string, error) {
+	if err := os.MkdirAll(svc.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create storage dir: %w", err)
+	}
+
+	f, err := os.Create(filepath.Join(svc.Dir, filepath.Base(name)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return fmt.Sprintf("%s/%s", svc.URLPath, filepath.Base(name)), nil
+}
+
+// s3API is the part of the S3 client S3FileStore uses
+type s3API interface {
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3FileStore stores files in an S3 bucket, or one on an S3-compatible
+// server like MinIO. The bucket has to allow public reads for the
+// returned URLs to work.
+type S3FileStore struct {
+	Conf   *config.S3Config
+	Client s3API
+}
+
+// NewS3FileStore creates an S3 client from the usual AWS environment
+// variables, shared config, or instance role, pointed at S3_ENDPOINT if
+// it's set
+func NewS3FileStore(conf *config.S3Config) (*S3FileStore, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if conf.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(conf.Region))
+	}
+
+	awsConf, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsConf, func(o *s3.Options) {
+		if conf.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(conf.Endpoint)
+			// MinIO and most other S3-compatible servers don't support
+			// bucket subdomains
+			o.UsePathStyle = true
+		}
+	})
+
+	if conf.Region == "" {
+		withRegion := *conf
+		withRegion.Region = awsConf.Region
+		conf = &withRegion
+	}
+
+	return &S3FileStore{Conf: conf, Client: client}, nil
+}
+
+func (svc *S3FileStore) Save(name string, r io.Reader, contentType string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+
+	key := path.Join(svc.Conf.Prefix, path.Base(name))
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(svc.Conf.Bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	if _, err := svc.Client.PutObject(ctx, input); err != nil {
+		return "", fmt.Errorf("failed to upload to s3: %w", err)
+	}
+
+	return svc.objectURL(key), nil
+}
+
+// objectURL is where the object at key can be downloaded from: under
+// S3_PUBLIC_URL when it's set (e.g. a CDN in front of the bucket), the
+// bucket's path on S3_ENDPOINT, or the bucket's own AWS domain
+func (svc *S3FileStore) objectURL(key string) string {
+	escaped := (&url.URL{Path: key}).EscapedPath()
+
+	switch {
+	case svc.Conf.PublicURL != "":
+		return strings.TrimSuffix(svc.Conf.PublicURL, "/") + "/" + escaped
+	case svc.Conf.Endpoint != "":
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(svc.Conf.Endpoint, "/"), svc.Conf.Bucket, escaped)
+	default:
+		return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", svc.Conf.Bucket, svc.Conf.Region, escaped)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/devict/job-board/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalFileStoreSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads")
+	store := &LocalFileStore{Dir: dir, URLPath: "/uploads"}
+
+	url, err := store.Save("logo.png", strings.NewReader("png bytes"), "image/png")
+	assert.NoError(t, err)
+	assert.Equal(t, "/uploads/logo.png", url)
+
+	content, err := os.ReadFile(filepath.Join(dir, "logo.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "png bytes", string(content))
+
+	// Names can't escape Dir
+	url, err = store.Save("../../escape.png", strings.NewReader("png bytes"), "image/png")
+	assert.NoError(t, err)
+	assert.Equal(t, "/uploads/escape.png", url)
+	assert.FileExists(t, filepath.Join(dir, "escape.png"))
+}
+
+type mockS3 struct {
+	puts   []*s3.PutObjectInput
+	bodies []string
+	err    error
+}
+
+func (m *mockS3) PutObject(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(input.Body)
+	m.puts = append(m.puts, input)
+	m.bodies = append(m.bodies, string(body))
+	return &s3.PutObjectOutput{}, m.err
+}
+
+func TestS3FileStoreSave(t *testing.T) {
+	client := &mockS3{}
+	store := &S3FileStore{Conf: &config.S3Config{Bucket: "jobs", Region: "us-east-2", Prefix: "logos"}, Client: client}
+
+	url, err := store.Save("logo.svg", strings.NewReader("<svg></svg>"), "image/svg+xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://jobs.s3.us-east-2.amazonaws.com/logos/logo.svg", url)
+
+	if !assert.Len(t, client.puts, 1) {
+		return
+	}
+	assert.Equal(t, "jobs", aws.ToString(client.puts[0].Bucket))
+	assert.Equal(t, "logos/logo.svg", aws.ToString(client.puts[0].Key))
+	assert.Equal(t, "image/svg+xml", aws.ToString(client.puts[0].ContentType))
+	assert.Equal(t, "<svg></svg>", client.bodies[0])
+}
+
+func TestS3FileStoreURL(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.S3Config
+		want string
+	}{
+		{"aws", config.S3Config{Bucket: "jobs", Region: "us-east-2"}, "https://jobs.s3.us-east-2.amazonaws.com/logo.png"},
+		{"endpoint", config.S3Config{Bucket: "jobs", Endpoint: "http://minio:9000/"}, "http://minio:9000/jobs/logo.png"},
+		{"public url", config.S3Config{Bucket: "jobs", Endpoint: "http://minio:9000", PublicURL: "https://cdn.devict.org/"}, "https://cdn.devict.org/logo.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &S3FileStore{Conf: &tt.conf, Client: &mockS3{}}
+
+			url, err := store.Save("logo.png", strings.NewReader(""), "image/png")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, url)
+		})
+	}
+}
+
+func TestS3FileStoreError(t *testing.T) {
+	store := &S3FileStore{Conf: &config.S3Config{Bucket: "jobs"}, Client: &mockS3{err: errors.New("AccessDenied")}}
+
+	_, err := store.Save("logo.png", strings.NewReader(""), "image/png")
+	assert.ErrorContains(t, err, "AccessDenied")
+}
+
+func TestNewFileStore(t *testing.T) {
+	store, err := NewFileStore(&config.Config{UploadDir: "uploads"})
+	assert.NoError(t, err)
+	assert.Equal(t, &LocalFileStore{Dir: "uploads", URLPath: "/uploads"}, store)
+
+	store, err = NewFileStore(&config.Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, store)
+
+	t.Setenv("AWS_REGION", "us-east-2")
+	store, err = NewFileStore(&config.Config{UploadDir: "uploads", S3: &config.S3Config{Bucket: "jobs"}})
+	assert.NoError(t, err)
+	if assert.IsType(t, &S3FileStore{}, store) {
+		assert.Equal(t, "us-east-2", store.(*S3FileStore).Conf.Region)
+	}
+}
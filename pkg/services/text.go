@@ -0,0 +1,19 @@
+package services
+
+import "strings"
+
+// truncate shortens s to at most n runes, cutting at a word boundary where
+// possible and marking the cut with an ellipsis.
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	cut := string(runes[:n-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/devict/job-board/pkg/config"
 	"github.com/devict/job-board/pkg/data"
@@ -18,7 +20,10 @@ type TwitterService struct {
 }
 
 func (svc *TwitterService) PostToTwitter(job data.Job) error {
-	tweetStr := tweetFromJob(job, svc.Conf)
+	tweetStr, err := tweetFromJob(job, svc.Conf)
+	if err != nil {
+		return err
+	}
 
 	oa := oauth1.NewConfig(svc.Conf.Twitter.APIKey, svc.Conf.Twitter.APISecretKey)
 	token := oauth1.NewToken(
@@ -30,7 +35,7 @@ func (svc *TwitterService) PostToTwitter(job data.Job) error {
 	twClient := twitter.NewClient(httpClient)
 
 	// TODO: check for failures in the resp object?
-	_, _, err := twClient.Statuses.Update(tweetStr, nil)
+	_, _, err = twClient.Statuses.Update(tweetStr, nil)
 	if err != nil {
 		return fmt.Errorf("failed to post to twitter: %w", err)
 	}
@@ -38,12 +43,47 @@ func (svc *TwitterService) PostToTwitter(job data.Job) error {
 	return nil
 }
 
-func tweetFromJob(job data.Job, c *config.Config) string {
-	return fmt.Sprintf(
-		"A job was posted! -- %s at %s\n\nMore info at %s/jobs/%s",
-		job.Position,
-		job.Organization,
-		c.URL,
-		job.ID,
-	)
+const (
+	tweetLength = 280
+	// tweetURLLength is how many characters any link counts as, since
+	// twitter shortens them all with t.co
+	tweetURLLength = 23
+)
+
+// tweetFromJob uses TWEET_TEMPLATE when it's set. If what that renders is
+// too long, it's truncated the same way as the built-in tweet, with the
+// link moved to the end so it isn't cut off.
+func tweetFromJob(job data.Job, c *config.Config) (string, error) {
+	link := c.URL + job.Path()
+
+	if c.TweetTemplate != "" {
+		text, err := renderNotification(c.TweetTemplate, config.NotificationFields{
+			Position:     job.Position,
+			Organization: job.Organization,
+			Url:          link,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		links := strings.Count(text, link)
+		if utf8.RuneCountInString(text)-links*(utf8.RuneCountInString(link)-tweetURLLength) <= tweetLength {
+			return text, nil
+		}
+		return composeTweet(strings.TrimSpace(strings.ReplaceAll(text, link, "")), link), nil
+	}
+
+	return composeTweet(
+		fmt.Sprintf("A job was posted! -- %s at %s", job.Position, job.Organization),
+		link,
+	), nil
+}
+
+// composeTweet follows text with a link to more info, truncating the text
+// so that the tweet fits in tweetLength
+func composeTweet(text, link string) string {
+	const linkPrefix = "\n\nMore info at "
+	budget := tweetLength - utf8.RuneCountInString(linkPrefix) - tweetURLLength
+
+	return truncate(budget, text) + linkPrefix + link
 }
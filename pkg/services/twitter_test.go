@@ -0,0 +1,35 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTweetFromJob(t *testing.T) {
+	c := &config.Config{URL: "https://jobs.devict.org"}
+
+	tweet, err := tweetFromJob(data.Job{ID: "1", Position: "Pos", Organization: "Org"}, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "A job was posted! -- Pos at Org\n\nMore info at https://jobs.devict.org/jobs/1", tweet)
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: strings.Repeat("Very Long Organization Name ", 20),
+	}
+	c.URL = "https://" + strings.Repeat("long-subdomain.", 10) + "devict.org"
+	link := c.URL + "/jobs/1"
+
+	tweet, err = tweetFromJob(job, c)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(tweet, "…\n\nMore info at "+link), tweet)
+
+	// twitter counts the link as 23 characters however long it really is
+	counted := utf8.RuneCountInString(strings.TrimSuffix(tweet, link)) + tweetURLLength
+	assert.LessOrEqual(t, counted, tweetLength)
+}
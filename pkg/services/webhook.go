@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the app secret, so receivers can verify where it came from.
+const WebhookSignatureHeader = "X-Job-Board-Signature"
+
+const webhookTimeout = 10 * time.Second
+
+type IWebhookService interface {
+	PostJobToWebhooks(data.Job) error
+}
+
+type WebhookService struct {
+	Conf *config.Config
+}
+
+func WebhookSignature(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostJobToWebhooks sends the job to every configured webhook url, trying
+// all of them even if some fail.
+func (svc *WebhookService) PostJobToWebhooks(job data.Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook body: %w", err)
+	}
+
+	signature := WebhookSignature(body, svc.Conf.AppSecret)
+	client := &http.Client{Timeout: webhookTimeout}
+
+	var failures []string
+	for _, url := range svc.Conf.WebhookURLs {
+		if err := postWebhook(client, url, body, signature); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("failed to deliver %d webhook(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+func postWebhook(client *http.Client, url string, body []byte, signature string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request for %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signature)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devict/job-board/pkg/config"
+	"github.com/devict/job-board/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostJobToWebhooks(t *testing.T) {
+	var received map[string]interface{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, WebhookSignature(body, "sup"), r.Header.Get(WebhookSignatureHeader))
+
+		assert.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer receiver.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	svc := &WebhookService{
+		Conf: &config.Config{AppSecret: "sup", WebhookURLs: []string{failing.URL, receiver.URL}},
+	}
+
+	job := data.Job{
+		ID:           "1",
+		Position:     "Pos",
+		Organization: "Org",
+		Url:          sql.NullString{String: "https://devict.org", Valid: true},
+		Email:        "secret@example.com",
+	}
+
+	// The failing receiver is reported, but doesn't stop delivery to the rest
+	err := svc.PostJobToWebhooks(job)
+	assert.ErrorContains(t, err, "status 500")
+
+	assert.Equal(t, "1", received["id"])
+	assert.Equal(t, "Pos", received["position"])
+	assert.Equal(t, "https://devict.org", received["url"])
+	assert.NotContains(t, received, "email")
+	assert.NotContains(t, received, "description")
+}
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS needs_review BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS logo_url TEXT;
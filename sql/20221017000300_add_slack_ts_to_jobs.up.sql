@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS slack_ts TEXT;
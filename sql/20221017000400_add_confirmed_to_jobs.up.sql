@@ -0,0 +1,3 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS confirmed BOOLEAN NOT NULL DEFAULT false;
+-- jobs posted before confirmation existed are already public
+UPDATE jobs SET confirmed = true;
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS featured BOOLEAN NOT NULL DEFAULT false;
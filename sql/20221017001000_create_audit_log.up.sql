@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+  id SERIAL PRIMARY KEY,
+  job_id INTEGER NOT NULL,
+  actor TEXT NOT NULL,
+  action TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_job_id_idx ON audit_log (job_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS job_revisions (
+  id SERIAL PRIMARY KEY,
+  job_id INTEGER NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+  position TEXT NOT NULL,
+  organization TEXT NOT NULL,
+  url TEXT,
+  description TEXT,
+  metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+  logo_url TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS job_revisions_job_id_idx ON job_revisions (job_id);
@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS apply_count INTEGER NOT NULL DEFAULT 0;
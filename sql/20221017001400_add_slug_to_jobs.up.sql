@@ -0,0 +1,6 @@
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS slug TEXT NOT NULL DEFAULT '';
+
+UPDATE jobs SET slug = trim(BOTH '-' FROM regexp_replace(lower(position || ' ' || organization), '[^[:alnum:]]+', '-', 'g'));
+
+-- Slugs can't shadow the routes under /jobs/:id/
+UPDATE jobs SET slug = slug || '-job' WHERE slug IN ('apply', 'edit', 'delete', 'confirm');
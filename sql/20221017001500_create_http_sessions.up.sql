@@ -0,0 +1,12 @@
+-- The table pgstore keeps sessions in when DB_SESSIONS is set
+CREATE TABLE IF NOT EXISTS http_sessions (
+  id BIGSERIAL PRIMARY KEY,
+  key BYTEA,
+  data BYTEA,
+  created_on TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  modified_on TIMESTAMPTZ,
+  expires_on TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS http_sessions_key_idx ON http_sessions (key);
+CREATE INDEX IF NOT EXISTS http_sessions_expires_on_idx ON http_sessions (expires_on);